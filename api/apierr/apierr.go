@@ -0,0 +1,142 @@
+// Package apierr describes errors returned by VK Teams Bot API
+// and decodes API responses in a uniform way
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var ErrNotOk = errors.New("response status is not ok")
+
+// Max length of response body kept in APIError
+const bodySnippetLength = 512
+
+// APIError is returned when API responds with non 2xx status,
+// with invalid JSON or with "ok": false.
+// It matches ErrNotOk with errors.Is
+type APIError struct {
+	// HTTP status code of response
+	StatusCode int
+	// "ok" field of response
+	Ok bool
+	// "description" field of response
+	Description string
+	// Path of requested endpoint
+	Path string
+	// Beginning of the raw response body
+	Body string
+	// Value of Retry-After header, if present
+	RetryAfter time.Duration
+	// Error occurred while decoding response, if any
+	Err error
+}
+
+func (e *APIError) Error() string {
+	msg := ErrNotOk.Error()
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	msg += fmt.Sprintf(" (path %s, status %d)", e.Path, e.StatusCode)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *APIError) Is(target error) bool { return target == ErrNotOk }
+
+func (e *APIError) Unwrap() error { return e.Err }
+
+// RateLimited reports whether request was throttled by API
+func (e *APIError) RateLimited() bool { return e.StatusCode == http.StatusTooManyRequests }
+
+// ServerError reports whether API failed to process request on its side
+func (e *APIError) ServerError() bool { return e.StatusCode >= http.StatusInternalServerError }
+
+// FromResponse builds APIError from response status, headers and body.
+// Response body is read but not closed
+func FromResponse(resp *http.Response) *APIError {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, bodySnippetLength))
+	e := newAPIError(resp, body)
+	if err != nil {
+		e.Err = err
+	}
+	return e
+}
+
+// Decode reads response and unmarshals it into v (may be nil).
+// Returns *APIError if response is not successful. Response body is not closed
+func Decode(resp *http.Response, v any) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		apiErr := newAPIError(resp, body)
+		apiErr.Err = fmt.Errorf("unable to read response: %w", err)
+		return apiErr
+	}
+
+	envelope := struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}{}
+	decodeErr := json.Unmarshal(body, &envelope)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || decodeErr != nil || !envelope.Ok {
+		apiErr := newAPIError(resp, body)
+		apiErr.Ok = envelope.Ok
+		apiErr.Description = envelope.Description
+		if decodeErr != nil {
+			apiErr.Err = fmt.Errorf("unable to decode response: %w", decodeErr)
+		}
+		return apiErr
+	}
+	if v == nil {
+		return nil
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		apiErr := newAPIError(resp, body)
+		apiErr.Ok = envelope.Ok
+		apiErr.Err = fmt.Errorf("unable to decode response: %w", err)
+		return apiErr
+	}
+	return nil
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		e.Path = resp.Request.URL.Path
+	}
+	if len(body) > bodySnippetLength {
+		body = body[:bodySnippetLength]
+	}
+	e.Body = string(body)
+	return e
+}
+
+// ParseRetryAfter parses value of Retry-After header (seconds or HTTP date).
+// Returns 0 if value is empty or invalid
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,88 @@
+package apierr_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/apierr"
+	"github.com/stretchr/testify/assert"
+)
+
+func newResponse(status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    &http.Request{URL: &url.URL{Path: "/bot/v1/messages/sendText"}},
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    *http.Response
+		wantErr *apierr.APIError
+		wantID  string
+	}{
+		{
+			name:   "Ok",
+			resp:   newResponse(http.StatusOK, nil, `{"ok":true,"msgId":"1"}`),
+			wantID: "1",
+		},
+		{
+			name: "Not ok",
+			resp: newResponse(http.StatusOK, nil, `{"ok":false,"description":"Chat not found"}`),
+			wantErr: &apierr.APIError{
+				StatusCode:  http.StatusOK,
+				Description: "Chat not found",
+				Path:        "/bot/v1/messages/sendText",
+				Body:        `{"ok":false,"description":"Chat not found"}`,
+			},
+		},
+		{
+			name: "Rate limited",
+			resp: newResponse(http.StatusTooManyRequests, http.Header{"Retry-After": {"3"}}, `{"ok":false}`),
+			wantErr: &apierr.APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Path:       "/bot/v1/messages/sendText",
+				Body:       `{"ok":false}`,
+				RetryAfter: 3 * time.Second,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := struct {
+				ID string `json:"msgId"`
+			}{}
+			err := apierr.Decode(tt.resp, &response)
+			assert.Equal(t, tt.wantID, response.ID)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, apierr.ErrNotOk)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestDecode_InvalidBody(t *testing.T) {
+	err := apierr.Decode(newResponse(http.StatusBadGateway, nil, "<html>Bad Gateway</html>"), nil)
+	var apiErr *apierr.APIError
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.True(t, apiErr.ServerError())
+		assert.False(t, apiErr.RateLimited())
+		assert.Equal(t, "<html>Bad Gateway</html>", apiErr.Body)
+		assert.Error(t, apiErr.Err)
+	}
+	assert.ErrorIs(t, err, apierr.ErrNotOk)
+}
@@ -0,0 +1,179 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/apierr"
+)
+
+type ChatService struct {
+	client Client
+}
+
+func New(cli Client) *ChatService { return &ChatService{cli} }
+
+// /chats/getInfo
+func (s *ChatService) GetChatInfo(ctx context.Context, chatID string) (*ChatInfo, error) {
+	info := &ChatInfo{}
+	if err := s.call(ctx, "/chats/getInfo", url.Values{"chatId": {chatID}}, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// /chats/getAdmins
+func (s *ChatService) GetAdmins(ctx context.Context, chatID string) ([]Admin, error) {
+	response := struct {
+		Admins []Admin `json:"admins"`
+	}{}
+	if err := s.call(ctx, "/chats/getAdmins", url.Values{"chatId": {chatID}}, &response); err != nil {
+		return nil, err
+	}
+	return response.Admins, nil
+}
+
+// /chats/getMembers. Pass empty cursor to get the first page
+func (s *ChatService) GetMembers(ctx context.Context, chatID string, cursor string) (*MembersPage, error) {
+	params := url.Values{"chatId": {chatID}}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	response := struct {
+		Members []Member `json:"members"`
+		Cursor  string   `json:"cursor"`
+	}{}
+	if err := s.call(ctx, "/chats/getMembers", params, &response); err != nil {
+		return nil, err
+	}
+	return &MembersPage{Members: response.Members, Cursor: response.Cursor}, nil
+}
+
+// /chats/getBlockedUsers
+func (s *ChatService) GetBlockedUsers(ctx context.Context, chatID string) ([]User, error) {
+	return s.getUsers(ctx, "/chats/getBlockedUsers", chatID)
+}
+
+// /chats/getPendingUsers
+func (s *ChatService) GetPendingUsers(ctx context.Context, chatID string) ([]User, error) {
+	return s.getUsers(ctx, "/chats/getPendingUsers", chatID)
+}
+
+// /chats/blockUser
+func (s *ChatService) BlockUser(ctx context.Context, block *BlockUser) error {
+	params := url.Values{
+		"chatId": {block.ChatID},
+		"userId": {block.UserID},
+	}
+	if block.DelLastMessages {
+		params.Set("delLastMessages", "true")
+	}
+	return s.call(ctx, "/chats/blockUser", params, nil)
+}
+
+// /chats/unblockUser
+func (s *ChatService) UnblockUser(ctx context.Context, chatID string, userID string) error {
+	return s.call(ctx, "/chats/unblockUser", url.Values{
+		"chatId": {chatID},
+		"userId": {userID},
+	}, nil)
+}
+
+// /chats/resolvePending
+func (s *ChatService) ResolvePending(ctx context.Context, resolve *ResolvePending) error {
+	params := url.Values{
+		"chatId":  {resolve.ChatID},
+		"approve": {strconv.FormatBool(resolve.Approve)},
+	}
+	if resolve.Everyone {
+		params.Set("everyone", "true")
+	} else {
+		params.Set("userId", resolve.UserID)
+	}
+	return s.call(ctx, "/chats/resolvePending", params, nil)
+}
+
+// /chats/members/delete
+func (s *ChatService) DeleteMembers(ctx context.Context, del *DeleteMembers) error {
+	members := make([]struct {
+		Sn string `json:"sn"`
+	}, len(del.UserIDs))
+	for i, userID := range del.UserIDs {
+		members[i].Sn = userID
+	}
+	bytes, err := json.Marshal(members)
+	if err != nil {
+		return fmt.Errorf("unable to encode members: %w", err)
+	}
+	return s.call(ctx, "/chats/members/delete", url.Values{
+		"chatId":  {del.ChatID},
+		"members": {string(bytes)},
+	}, nil)
+}
+
+// /chats/setTitle
+func (s *ChatService) SetTitle(ctx context.Context, chatID string, title string) error {
+	return s.call(ctx, "/chats/setTitle", url.Values{
+		"chatId": {chatID},
+		"title":  {title},
+	}, nil)
+}
+
+// /chats/setAbout
+func (s *ChatService) SetAbout(ctx context.Context, chatID string, about string) error {
+	return s.call(ctx, "/chats/setAbout", url.Values{
+		"chatId": {chatID},
+		"about":  {about},
+	}, nil)
+}
+
+// /chats/setRules
+func (s *ChatService) SetRules(ctx context.Context, chatID string, rules string) error {
+	return s.call(ctx, "/chats/setRules", url.Values{
+		"chatId": {chatID},
+		"rules":  {rules},
+	}, nil)
+}
+
+// /chats/pinMessage
+func (s *ChatService) PinMessage(ctx context.Context, chatID string, msgID string) error {
+	return s.call(ctx, "/chats/pinMessage", url.Values{
+		"chatId": {chatID},
+		"msgId":  {msgID},
+	}, nil)
+}
+
+// /chats/unpinMessage
+func (s *ChatService) UnpinMessage(ctx context.Context, chatID string, msgID string) error {
+	return s.call(ctx, "/chats/unpinMessage", url.Values{
+		"chatId": {chatID},
+		"msgId":  {msgID},
+	}, nil)
+}
+
+func (s *ChatService) getUsers(ctx context.Context, path string, chatID string) ([]User, error) {
+	response := struct {
+		Users []User `json:"users"`
+	}{}
+	if err := s.call(ctx, path, url.Values{"chatId": {chatID}}, &response); err != nil {
+		return nil, err
+	}
+	return response.Users, nil
+}
+
+func (s *ChatService) call(ctx context.Context, path string, params url.Values, response any) error {
+	req, err := s.client.PerformRequest(ctx, http.MethodGet, path, params, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to perform %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	return apierr.Decode(resp, response)
+}
@@ -0,0 +1,105 @@
+package chat_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/s1em0nk3y/vkteams-bot"
+	"github.com/s1em0nk3y/vkteams-bot/api/chat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T, handler http.HandlerFunc) *chat.ChatService {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return chat.New(vkteams.New("token", vkteams.WithApiURL(server.URL)))
+}
+
+func TestChatService_GetChatInfo(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chats/getInfo", r.URL.Path)
+		assert.Equal(t, "chat@chat.agent", r.URL.Query().Get("chatId"))
+		w.Write([]byte(`{"ok":true,"type":"group","title":"Title","rules":"No spam","joinModeration":true}`))
+	})
+	info, err := s.GetChatInfo(context.Background(), "chat@chat.agent")
+	require.NoError(t, err)
+	assert.Equal(t, &chat.ChatInfo{
+		Type:           chat.ChatTypeGroup,
+		Title:          "Title",
+		Rules:          "No spam",
+		JoinModeration: true,
+	}, info)
+}
+
+func TestChatService_GetMembers(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "next", r.URL.Query().Get("cursor"))
+		w.Write([]byte(`{"ok":true,"members":[{"userId":"user1","admin":true}],"cursor":"last"}`))
+	})
+	page, err := s.GetMembers(context.Background(), "chat", "next")
+	require.NoError(t, err)
+	assert.Equal(t, &chat.MembersPage{
+		Members: []chat.Member{{UserID: "user1", Admin: true}},
+		Cursor:  "last",
+	}, page)
+}
+
+func TestChatService_DeleteMembers(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chats/members/delete", r.URL.Path)
+		members := []map[string]string{}
+		assert.NoError(t, json.Unmarshal([]byte(r.URL.Query().Get("members")), &members))
+		assert.Equal(t, []map[string]string{{"sn": "user1"}, {"sn": "user2"}}, members)
+		w.Write([]byte(`{"ok":true}`))
+	})
+	assert.NoError(t, s.DeleteMembers(context.Background(), &chat.DeleteMembers{
+		ChatID:  "chat",
+		UserIDs: []string{"user1", "user2"},
+	}))
+}
+
+func TestChatService_NotOk(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":false,"description":"Permission denied"}`))
+	})
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{
+			name: "Block user",
+			call: func() error {
+				return s.BlockUser(context.Background(), &chat.BlockUser{ChatID: "chat", UserID: "user"})
+			},
+		},
+		{
+			name: "Pin message",
+			call: func() error { return s.PinMessage(context.Background(), "chat", "1") },
+		},
+		{
+			name: "Get admins",
+			call: func() error {
+				_, err := s.GetAdmins(context.Background(), "chat")
+				return err
+			},
+		},
+		{
+			name: "Get pending users",
+			call: func() error {
+				_, err := s.GetPendingUsers(context.Background(), "chat")
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			assert.ErrorIs(t, err, chat.ErrNotOk)
+			assert.ErrorContains(t, err, "Permission denied")
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package chat
+
+type ChatType string
+
+const (
+	ChatTypePrivate ChatType = "private"
+	ChatTypeGroup   ChatType = "group"
+	ChatTypeChannel ChatType = "channel"
+)
+
+// Response of /chats/getInfo. Fields are filled depending on chat type
+type ChatInfo struct {
+	Type ChatType `json:"type"`
+	// Private chats
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Nick      string `json:"nick"`
+	IsBot     bool   `json:"isBot"`
+	// Group chats and channels
+	Title          string `json:"title"`
+	About          string `json:"about"`
+	Rules          string `json:"rules"`
+	InviteLink     string `json:"inviteLink"`
+	Public         bool   `json:"public"`
+	JoinModeration bool   `json:"joinModeration"`
+}
+
+type Admin struct {
+	UserID  string `json:"userId"`
+	Creator bool   `json:"creator"`
+}
+
+type Member struct {
+	UserID  string `json:"userId"`
+	Creator bool   `json:"creator"`
+	Admin   bool   `json:"admin"`
+}
+
+type User struct {
+	UserID string `json:"userId"`
+}
+
+// Page of /chats/getMembers. Pass Cursor to the next call to get the next page
+type MembersPage struct {
+	Members []Member
+	Cursor  string
+}
+
+type BlockUser struct {
+	ChatID string
+	UserID string
+	// Delete last messages of the user in the chat
+	DelLastMessages bool
+}
+
+// Exactly one of UserID or Everyone must be set
+type ResolvePending struct {
+	ChatID   string
+	Approve  bool
+	UserID   string
+	Everyone bool
+}
+
+type DeleteMembers struct {
+	ChatID  string
+	UserIDs []string
+}
@@ -0,0 +1,154 @@
+package event
+
+import "github.com/s1em0nk3y/vkteams-bot/api/format"
+
+// Typed views of Event. Every accessor returns false if event has another type
+
+// MessageEvent is newMessage or editedMessage event
+type MessageEvent struct {
+	MessageID       string
+	Chat            Chat
+	From            Contact
+	Timestamp       int
+	EditedTimestamp int
+	Text            string
+	Format          *format.Format
+	Parts           []Part
+}
+
+type DeletedMessageEvent struct {
+	MessageID string
+	Chat      Chat
+	Timestamp int
+}
+
+// PinEvent is pinnedMessage or unpinnedMessage event
+type PinEvent struct {
+	Pinned    bool
+	MessageID string
+	Chat      Chat
+	From      Contact
+	Text      string
+	Timestamp int
+}
+
+type CallbackQuery struct {
+	QueryID string
+	// User who pressed the button
+	From Contact
+	// Message with the button
+	Message BasePayload
+	Data    string
+}
+
+// MembersChange is newChatMembers or leftChatMembers event
+type MembersChange struct {
+	Joined  bool
+	Chat    Chat
+	Members []Contact
+	// User who added or removed members
+	By Contact
+}
+
+type ChatInfoChange struct {
+	Chat  Chat
+	From  Contact
+	Title string
+	About string
+	Rules string
+}
+
+func (e Event) AsNewMessage() (*MessageEvent, bool) {
+	if e.Type != EventNewMessage {
+		return nil, false
+	}
+	return e.messageEvent(), true
+}
+
+func (e Event) AsEditedMessage() (*MessageEvent, bool) {
+	if e.Type != EventEditedMessage {
+		return nil, false
+	}
+	return e.messageEvent(), true
+}
+
+func (e Event) messageEvent() *MessageEvent {
+	return &MessageEvent{
+		MessageID:       e.MessageID,
+		Chat:            e.Chat,
+		From:            e.From,
+		Timestamp:       e.Timestamp,
+		EditedTimestamp: e.EditedTimestamp,
+		Text:            e.Text,
+		Format:          e.Format,
+		Parts:           e.Parts,
+	}
+}
+
+func (e Event) AsDeletedMessage() (*DeletedMessageEvent, bool) {
+	if e.Type != EventDeletedMessage {
+		return nil, false
+	}
+	return &DeletedMessageEvent{
+		MessageID: e.MessageID,
+		Chat:      e.Chat,
+		Timestamp: e.Timestamp,
+	}, true
+}
+
+func (e Event) AsPin() (*PinEvent, bool) {
+	if e.Type != EventPinnedMessage && e.Type != EventUnpinnedMessage {
+		return nil, false
+	}
+	return &PinEvent{
+		Pinned:    e.Type == EventPinnedMessage,
+		MessageID: e.MessageID,
+		Chat:      e.Chat,
+		From:      e.From,
+		Text:      e.Text,
+		Timestamp: e.Timestamp,
+	}, true
+}
+
+func (e Event) AsCallback() (*CallbackQuery, bool) {
+	if e.Type != EventCallbackQuery {
+		return nil, false
+	}
+	return &CallbackQuery{
+		QueryID: e.QueryID,
+		From:    e.From,
+		Message: e.CallbackMessage,
+		Data:    e.CallbackData,
+	}, true
+}
+
+func (e Event) AsMembersChange() (*MembersChange, bool) {
+	switch e.Type {
+	case EventNewChatMembers:
+		return &MembersChange{Joined: true, Chat: e.Chat, Members: e.MembersNew, By: e.AddedBy}, true
+	case EventLeftChatMembers:
+		return &MembersChange{Chat: e.Chat, Members: e.MembersLeft, By: e.RemovedBy}, true
+	}
+	return nil, false
+}
+
+func (e Event) AsChatInfoChange() (*ChatInfoChange, bool) {
+	if e.Type != EventChangedChatInfo {
+		return nil, false
+	}
+	return &ChatInfoChange{
+		Chat:  e.Chat,
+		From:  e.From,
+		Title: e.Title,
+		About: e.About,
+		Rules: e.Rules,
+	}, true
+}
+
+// ChatID returns ID of chat the event belongs to. Callback queries have it in the message with the button
+func (e Event) ChatID() string {
+	if e.Type == EventCallbackQuery {
+		return e.CallbackMessage.Chat.ID
+	}
+	return e.Chat.ID
+}
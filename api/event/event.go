@@ -2,34 +2,60 @@ package event
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/url"
 	"strconv"
-	"time"
+	"sync"
 
 	"github.com/rs/zerolog"
+	"github.com/s1em0nk3y/vkteams-bot/api/apierr"
+	"github.com/s1em0nk3y/vkteams-bot/retry"
 )
 
 type EventService struct {
 	cli         Client
 	pollSeconds uint
+	retryPolicy retry.Policy
+	offsets     OffsetStore
+	startMode   StartMode
+
+	commitMu  sync.Mutex
+	committed int
+
+	errMu      sync.Mutex
+	updatesErr error
 }
 
-func New(cli Client, pollSeconds uint) *EventService { return &EventService{cli, pollSeconds} }
+func New(cli Client, pollSeconds uint, opts ...Option) *EventService {
+	e := &EventService{
+		cli:         cli,
+		pollSeconds: pollSeconds,
+		retryPolicy: retry.DefaultPolicy(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
 
+// UpdatesChannel polls events until context is done. If start event can not be determined
+// (e.g. OffsetStore fails), the channel is closed and the error is returned by UpdatesErr
 func (e *EventService) UpdatesChannel(ctx context.Context) <-chan Event {
 	ch := make(chan Event)
 	log := zerolog.Ctx(ctx).With().Str("service", "event").Logger()
 	log.Info().Msg("Start listen")
+	e.setUpdatesErr(nil)
 	go func() {
-		lastEventId := 0
 		defer close(ch)
-		events, err := e.pollEvents(ctx, lastEventId, 0)
-		log.Err(err).Int("event_count", len(events)).Msg("Drop unread messages")
-		if length := len(events); length > 0 {
-			lastEventId = events[length-1].ID
+		lastEventId, err := e.startEventID(ctx)
+		if err != nil {
+			log.Err(err).Msg("unable to get start event id; exiting")
+			if ctx.Err() == nil {
+				e.setUpdatesErr(fmt.Errorf("unable to get start event id: %w", err))
+			}
+			return
 		}
+		failures := 0
 		for {
 			select {
 			case <-ctx.Done():
@@ -37,12 +63,18 @@ func (e *EventService) UpdatesChannel(ctx context.Context) <-chan Event {
 				return
 			default:
 				log.Info().Int("event_id", lastEventId).Msg("Fetching events")
-				events, err = e.pollEvents(ctx, lastEventId, int(e.pollSeconds))
+				events, err := e.pollEvents(ctx, lastEventId, int(e.pollSeconds))
 				if err != nil {
-					log.Err(err).Msg("Error occured; sleeping 5s")
-					time.Sleep(time.Second * 5)
+					failures++
+					delay := e.retryPolicy.Delay(failures)
+					log.Err(err).Dur("delay", delay).Msg("Error occured; sleeping")
+					if err = retry.Sleep(ctx, delay); err != nil {
+						log.Info().Err(err).Msg("context done; exiting")
+						return
+					}
 					continue
 				}
+				failures = 0
 				for _, event := range events {
 					select {
 					case <-ctx.Done():
@@ -61,6 +93,63 @@ func (e *EventService) UpdatesChannel(ctx context.Context) <-chan Event {
 	return ch
 }
 
+// UpdatesErr returns error which closed the last channel of UpdatesChannel, see event.Stopper
+func (e *EventService) UpdatesErr() error {
+	e.errMu.Lock()
+	defer e.errMu.Unlock()
+	return e.updatesErr
+}
+
+func (e *EventService) setUpdatesErr(err error) {
+	e.errMu.Lock()
+	defer e.errMu.Unlock()
+	e.updatesErr = err
+}
+
+// startEventID returns ID of event after which events are delivered
+func (e *EventService) startEventID(ctx context.Context) (int, error) {
+	log := zerolog.Ctx(ctx).With().Str("service", "event").Logger()
+	if e.startMode == StartResume || (e.startMode == StartAuto && e.offsets != nil) {
+		if e.offsets == nil {
+			return 0, nil
+		}
+		eventID, err := e.offsets.Load(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("unable to load offset: %w", err)
+		}
+		log.Info().Int("event_id", eventID).Msg("Resume from stored offset")
+		return eventID, nil
+	}
+	events, err := e.pollEvents(ctx, 0, 0)
+	log.Err(err).Int("event_count", len(events)).Msg("Drop unread messages")
+	if length := len(events); length > 0 {
+		eventID := events[length-1].ID
+		if err = e.Commit(ctx, eventID); err != nil {
+			log.Err(err).Msg("unable to commit dropped events")
+		}
+		return eventID, nil
+	}
+	return 0, nil
+}
+
+// Commit saves ID of handled event to OffsetStore, so it is not delivered after restart.
+// IDs lower than already committed are ignored. Without OffsetStore it does nothing
+func (e *EventService) Commit(ctx context.Context, eventID int) error {
+	if e.offsets == nil {
+		return nil
+	}
+	e.commitMu.Lock()
+	defer e.commitMu.Unlock()
+	if eventID <= e.committed {
+		return nil
+	}
+	if err := e.offsets.Save(ctx, eventID); err != nil {
+		return err
+	}
+	e.committed = eventID
+	return nil
+}
+
 func (e *EventService) pollEvents(ctx context.Context, lastEventID int, pollTime int) ([]Event, error) {
 	params := url.Values{
 		"lastEventId": {strconv.Itoa(lastEventID)},
@@ -79,11 +168,9 @@ func (e *EventService) pollEvents(ctx context.Context, lastEventID int, pollTime
 	defer resp.Body.Close()
 
 	response := struct {
-		Ok     bool    `json:"ok"`
 		Events []Event `json:"events"`
 	}{}
-
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err = apierr.Decode(resp, &response); err != nil {
 		return nil, err
 	}
 	return response.Events, nil
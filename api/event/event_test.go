@@ -0,0 +1,191 @@
+package event_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/s1em0nk3y/vkteams-bot"
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileOffsetStore(t *testing.T) {
+	ctx := context.Background()
+	store := event.NewFileOffsetStore(filepath.Join(t.TempDir(), "offset"))
+	eventID, err := store.Load(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, eventID)
+
+	require.NoError(t, store.Save(ctx, 42))
+	eventID, err = store.Load(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, eventID)
+}
+
+// newEventServer serves events with IDs 1..total, one per poll
+func newEventServer(t *testing.T, total int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastEventID, _ := strconv.Atoi(r.URL.Query().Get("lastEventId"))
+		if lastEventID >= total {
+			w.Write([]byte(`{"ok":true,"events":[]}`))
+			return
+		}
+		id := strconv.Itoa(lastEventID + 1)
+		w.Write([]byte(`{"ok":true,"events":[{"eventId":` + id + `,"type":"newMessage","payload":{"text":"` + id + `"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func readEvents(t *testing.T, s *event.EventService, n int) []int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ids := []int{}
+	for ev := range s.UpdatesChannel(ctx) {
+		ids = append(ids, ev.ID)
+		if len(ids) == n {
+			break
+		}
+	}
+	return ids
+}
+
+func TestEventService_StartMode(t *testing.T) {
+	server := newEventServer(t, 5)
+	bot := vkteams.New("token", vkteams.WithApiURL(server.URL), vkteams.WithPollSeconds(0))
+	tests := []struct {
+		name     string
+		opts     []event.Option
+		stored   int
+		wantIDs  []int
+		wantSave int
+	}{
+		{
+			name:     "Resume from stored offset",
+			opts:     []event.Option{event.WithStartMode(event.StartResume)},
+			stored:   2,
+			wantIDs:  []int{3, 4},
+			wantSave: 2,
+		},
+		{
+			name:     "Auto resumes when store is set",
+			stored:   3,
+			wantIDs:  []int{4, 5},
+			wantSave: 3,
+		},
+		{
+			name:     "Drop pending",
+			opts:     []event.Option{event.WithStartMode(event.StartDropPending)},
+			stored:   0,
+			wantIDs:  []int{2, 3},
+			wantSave: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := event.NewMemoryOffsetStore()
+			store.Save(context.Background(), tt.stored)
+			s := event.New(bot, 0, append(tt.opts, event.WithOffsetStore(store))...)
+			assert.Equal(t, tt.wantIDs, readEvents(t, s, len(tt.wantIDs)))
+			saved, _ := store.Load(context.Background())
+			assert.Equal(t, tt.wantSave, saved)
+		})
+	}
+}
+
+func TestEventService_Commit(t *testing.T) {
+	ctx := context.Background()
+	store := event.NewMemoryOffsetStore()
+	s := event.New(nil, 0, event.WithOffsetStore(store))
+	assert.NoError(t, s.Commit(ctx, 5))
+	assert.NoError(t, s.Commit(ctx, 3))
+	saved, _ := store.Load(ctx)
+	assert.Equal(t, 5, saved, "lower event id must not be committed")
+
+	assert.NoError(t, event.New(nil, 0).Commit(ctx, 1), "commit without store does nothing")
+}
+
+func TestEvent_Accessors(t *testing.T) {
+	decode := func(data string) event.Event {
+		ev := event.Event{}
+		require.NoError(t, json.Unmarshal([]byte(data), &ev))
+		return ev
+	}
+
+	callback := decode(`{"eventId":1,"type":"callbackQuery","payload":{
+		"queryId":"q1","callbackData":"approve",
+		"from":{"userId":"user"},
+		"message":{"msgId":"m1","chat":{"chatId":"chat"},"text":"Approve?"}}}`)
+	query, ok := callback.AsCallback()
+	require.True(t, ok)
+	assert.Equal(t, &event.CallbackQuery{
+		QueryID: "q1",
+		From:    event.Contact{UserID: "user"},
+		Message: event.BasePayload{MessageID: "m1", Chat: event.Chat{ID: "chat"}, Text: "Approve?"},
+		Data:    "approve",
+	}, query)
+	_, ok = callback.AsNewMessage()
+	assert.False(t, ok)
+
+	left := decode(`{"eventId":2,"type":"leftChatMembers","payload":{
+		"chat":{"chatId":"chat"},
+		"leftMembers":[{"userId":"user1"}],
+		"removedBy":{"userId":"admin"}}}`)
+	change, ok := left.AsMembersChange()
+	require.True(t, ok)
+	assert.Equal(t, &event.MembersChange{
+		Chat:    event.Chat{ID: "chat"},
+		Members: []event.Contact{{UserID: "user1"}},
+		By:      event.Contact{UserID: "admin"},
+	}, change)
+
+	unpinned := decode(`{"eventId":3,"type":"unpinnedMessage","payload":{"msgId":"m1","chat":{"chatId":"chat"}}}`)
+	pin, ok := unpinned.AsPin()
+	require.True(t, ok)
+	assert.False(t, pin.Pinned)
+	assert.Equal(t, "m1", pin.MessageID)
+
+	info := decode(`{"eventId":4,"type":"changedChatInfo","payload":{"chat":{"chatId":"chat"},"title":"New title"}}`)
+	infoChange, ok := info.AsChatInfoChange()
+	require.True(t, ok)
+	assert.Equal(t, "New title", infoChange.Title)
+
+	assert.Equal(t, "chat", callback.ChatID(), "callback chat is taken from message")
+	assert.Equal(t, "chat", left.ChatID())
+}
+
+func TestEvent_Raw(t *testing.T) {
+	data := `{"eventId":7,"type":"newReaction","payload":{"chat":{"chatId":"chat"},"reaction":"like"}}`
+	ev := event.Event{}
+	require.NoError(t, json.Unmarshal([]byte(data), &ev))
+	assert.Equal(t, 7, ev.ID)
+	assert.Equal(t, "chat", ev.Chat.ID)
+	assert.False(t, ev.Type.Known())
+	assert.JSONEq(t, data, string(ev.Raw()))
+
+	reaction := struct {
+		Reaction string `json:"reaction"`
+	}{}
+	require.NoError(t, json.Unmarshal(ev.RawPayload(), &reaction))
+	assert.Equal(t, "like", reaction.Reaction)
+	assert.True(t, event.EventNewMessage.Known())
+}
+
+func TestEvent_Format(t *testing.T) {
+	ev := event.Event{}
+	require.NoError(t, json.Unmarshal([]byte(`{"eventId":1,"type":"newMessage","payload":{
+		"text":"👍 bold link",
+		"format":{"bold":[{"offset":3,"length":4}],"link":[{"offset":8,"length":4,"url":"https://example.com"}]}}}`), &ev))
+	msg, ok := ev.AsNewMessage()
+	require.True(t, ok)
+	require.NotNil(t, msg.Format)
+	assert.Equal(t, "bold", msg.Format.Bold[0].Extract(msg.Text))
+	assert.Equal(t, "link", msg.Format.Link[0].Extract(msg.Text))
+	assert.Equal(t, "https://example.com", msg.Format.Link[0].URL)
+}
@@ -0,0 +1,109 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OffsetStore keeps ID of the last handled event between restarts
+type OffsetStore interface {
+	// Load returns stored event ID or 0 if nothing is stored
+	Load(ctx context.Context) (int, error)
+	Save(ctx context.Context, eventID int) error
+}
+
+// StartMode defines which events UpdatesChannel delivers after start
+type StartMode int
+
+const (
+	// Resume from OffsetStore if it is configured, otherwise drop pending events
+	StartAuto StartMode = iota
+	// Drop events received while bot was not running
+	StartDropPending
+	// Deliver events after the one stored in OffsetStore (all pending events if store is empty)
+	StartResume
+)
+
+// Committer is implemented by event sources which support checkpointing
+type Committer interface {
+	Commit(ctx context.Context, eventID int) error
+}
+
+// Stopper is implemented by event sources which can stop delivering events on error
+type Stopper interface {
+	// UpdatesErr returns error which closed the last channel of UpdatesChannel;
+	// nil if it is open or was closed because context is done
+	UpdatesErr() error
+}
+
+type MemoryOffsetStore struct {
+	mu      sync.Mutex
+	eventID int
+}
+
+func NewMemoryOffsetStore() *MemoryOffsetStore { return &MemoryOffsetStore{} }
+
+func (s *MemoryOffsetStore) Load(context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.eventID, nil
+}
+
+func (s *MemoryOffsetStore) Save(_ context.Context, eventID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eventID = eventID
+	return nil
+}
+
+// FileOffsetStore keeps event ID in a text file. File is replaced atomically on save
+type FileOffsetStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileOffsetStore(path string) *FileOffsetStore { return &FileOffsetStore{path: path} }
+
+func (s *FileOffsetStore) Load(context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to read offset: %w", err)
+	}
+	eventID, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse offset: %w", err)
+	}
+	return eventID, nil
+}
+
+func (s *FileOffsetStore) Save(_ context.Context, eventID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to save offset: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.WriteString(strconv.Itoa(eventID)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to save offset: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("unable to save offset: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("unable to save offset: %w", err)
+	}
+	return nil
+}
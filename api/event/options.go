@@ -0,0 +1,28 @@
+package event
+
+import "github.com/s1em0nk3y/vkteams-bot/retry"
+
+type Option func(*EventService)
+
+// WithRetryPolicy sets delays between failed polls.
+// Poller never gives up, so MaxAttempts is ignored
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(e *EventService) {
+		e.retryPolicy = policy
+	}
+}
+
+// WithOffsetStore enables checkpointing: UpdatesChannel resumes from stored event ID
+// (unless StartDropPending is set) and Commit saves IDs of handled events
+func WithOffsetStore(store OffsetStore) Option {
+	return func(e *EventService) {
+		e.offsets = store
+	}
+}
+
+// WithStartMode sets which events are delivered after start (default StartAuto)
+func WithStartMode(mode StartMode) Option {
+	return func(e *EventService) {
+		e.startMode = mode
+	}
+}
@@ -0,0 +1,42 @@
+package event
+
+import "encoding/json"
+
+var knownEventTypes = map[EventType]bool{
+	EventNewMessage:      true,
+	EventEditedMessage:   true,
+	EventDeletedMessage:  true,
+	EventPinnedMessage:   true,
+	EventUnpinnedMessage: true,
+	EventNewChatMembers:  true,
+	EventLeftChatMembers: true,
+	EventChangedChatInfo: true,
+	EventCallbackQuery:   true,
+}
+
+// Known reports whether event type is known by the library.
+// Unknown events are still delivered; use Raw to access their contents
+func (t EventType) Known() bool { return knownEventTypes[t] }
+
+// UnmarshalJSON decodes event keeping its raw JSON
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type plainEvent Event
+	if err := json.Unmarshal(data, (*plainEvent)(e)); err != nil {
+		return err
+	}
+	raw := struct {
+		Payload json.RawMessage `json:"payload"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	e.raw = append(json.RawMessage(nil), data...)
+	e.rawPayload = raw.Payload
+	return nil
+}
+
+// Raw returns event JSON as it was received from API (nil for events built manually)
+func (e Event) Raw() json.RawMessage { return e.raw }
+
+// RawPayload returns "payload" object of event JSON
+func (e Event) RawPayload() json.RawMessage { return e.rawPayload }
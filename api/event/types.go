@@ -1,5 +1,11 @@
 package event
 
+import (
+	"encoding/json"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/format"
+)
+
 type ChatType string
 type EventType string
 
@@ -16,12 +22,32 @@ const (
 	EventUnpinnedMessage EventType = "unpinnedMessage"
 	EventNewChatMembers  EventType = "newChatMembers"
 	EventLeftChatMembers EventType = "leftChatMembers"
+	EventChangedChatInfo EventType = "changedChatInfo"
+	EventCallbackQuery   EventType = "callbackQuery"
 )
 
 type Event struct {
 	ID      int       `json:"eventId"`
 	Type    EventType `json:"type"`
 	Payload `json:"payload"`
+
+	raw        json.RawMessage
+	rawPayload json.RawMessage
+}
+
+// SentBy reports whether the event was initiated by user with given ID
+func (e Event) SentBy(userID string) bool {
+	return e.From.UserID == userID
+}
+
+// Mentions reports whether the message has a mention of user with given ID
+func (e Event) Mentions(userID string) bool {
+	for _, part := range e.Parts {
+		if part.Type == PartTypeMention && part.Payload.UserID == userID {
+			return true
+		}
+	}
+	return false
 }
 
 type Payload struct {
@@ -39,6 +65,11 @@ type Payload struct {
 	MembersNew  []Contact `json:"newMembers"`
 	AddedBy     Contact   `json:"addedBy"`
 	RemovedBy   Contact   `json:"removedBy"`
+
+	// For changedChatInfo
+	Title string `json:"title"`
+	About string `json:"about"`
+	Rules string `json:"rules"`
 }
 
 type BasePayload struct {
@@ -47,8 +78,9 @@ type BasePayload struct {
 	From      Contact `json:"from"`
 	Timestamp int     `json:"timestamp"`
 	Text      string  `json:"text"`
-	// TODO: Format `json:"format"`
-	EditedTimestamp int `json:"editedTimestamp"`
+	// Styles of Text; nil if message is not formatted
+	Format          *format.Format `json:"format"`
+	EditedTimestamp int            `json:"editedTimestamp"`
 }
 
 type Contact struct {
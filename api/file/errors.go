@@ -0,0 +1,7 @@
+package file
+
+import "github.com/s1em0nk3y/vkteams-bot/api/apierr"
+
+var ErrNotOk = apierr.ErrNotOk
+
+type APIError = apierr.APIError
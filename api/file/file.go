@@ -0,0 +1,62 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/apierr"
+)
+
+type FileService struct {
+	client Client
+}
+
+func New(cli Client) *FileService { return &FileService{cli} }
+
+// /files/getInfo
+func (s *FileService) GetInfo(ctx context.Context, fileID string) (*FileInfo, error) {
+	params := url.Values{
+		"fileId": {fileID},
+	}
+	req, err := s.client.PerformRequest(ctx, http.MethodGet, "/files/getInfo", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	info := &FileInfo{}
+	if err = apierr.Decode(resp, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Download resolves file by its ID and streams its contents.
+// Caller must close returned reader
+func (s *FileService) Download(ctx context.Context, fileID string) (io.ReadCloser, *FileInfo, error) {
+	info, err := s.GetInfo(ctx, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, info.URL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to build download request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to download file: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, nil, apierr.FromResponse(resp)
+	}
+	return resp.Body, info, nil
+}
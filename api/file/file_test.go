@@ -0,0 +1,50 @@
+package file_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/s1em0nk3y/vkteams-bot"
+	"github.com/s1em0nk3y/vkteams-bot/api/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileService_Download(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/getInfo":
+			if r.URL.Query().Get("fileId") != "file1" {
+				w.Write([]byte(`{"ok":false,"description":"File not found"}`))
+				return
+			}
+			w.Write([]byte(`{"ok":true,"type":"text","size":12,"filename":"report.txt","url":"` + server.URL + `/get/file1"}`))
+		case "/get/file1":
+			w.Write([]byte("file content"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	s := file.New(vkteams.New("token", vkteams.WithApiURL(server.URL)))
+
+	t.Run("Correct usage", func(t *testing.T) {
+		body, info, err := s.Download(context.Background(), "file1")
+		require.NoError(t, err)
+		defer body.Close()
+		assert.Equal(t, &file.FileInfo{Type: "text", Size: 12, Filename: "report.txt", URL: server.URL + "/get/file1"}, info)
+		content, err := io.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "file content", string(content))
+	})
+	t.Run("Non exist file", func(t *testing.T) {
+		body, info, err := s.Download(context.Background(), "file2")
+		assert.ErrorIs(t, err, file.ErrNotOk)
+		assert.Nil(t, body)
+		assert.Nil(t, info)
+	})
+}
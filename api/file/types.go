@@ -0,0 +1,8 @@
+package file
+
+type FileInfo struct {
+	Type     string `json:"type"`
+	Size     int64  `json:"size"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+}
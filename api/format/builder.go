@@ -0,0 +1,95 @@
+package format
+
+import "strings"
+
+// Builder composes text together with its format, computing offsets automatically:
+//
+//	b := &format.Builder{}
+//	b.Text("Build ").Bold("passed").Text(", see ").Link("logs", "https://ci.example/42")
+//	msg.Text, msg.MessageFormat = b.String(), b.Format()
+type Builder struct {
+	text   strings.Builder
+	length int
+	format Format
+}
+
+// Text appends plain text
+func (b *Builder) Text(text string) *Builder {
+	b.write(text)
+	return b
+}
+
+func (b *Builder) Bold(text string) *Builder {
+	b.format.Bold = append(b.format.Bold, b.write(text))
+	return b
+}
+
+func (b *Builder) Italic(text string) *Builder {
+	b.format.Italic = append(b.format.Italic, b.write(text))
+	return b
+}
+
+func (b *Builder) Underline(text string) *Builder {
+	b.format.Underline = append(b.format.Underline, b.write(text))
+	return b
+}
+
+func (b *Builder) Strikethrough(text string) *Builder {
+	b.format.Strikethrough = append(b.format.Strikethrough, b.write(text))
+	return b
+}
+
+func (b *Builder) Link(text string, url string) *Builder {
+	b.format.Link = append(b.format.Link, Link{Range: b.write(text), URL: url})
+	return b
+}
+
+// Mention appends mention of user, e.g. @[user@example.com]
+func (b *Builder) Mention(userID string) *Builder {
+	b.format.Mention = append(b.format.Mention, b.write("@["+userID+"]"))
+	return b
+}
+
+func (b *Builder) InlineCode(text string) *Builder {
+	b.format.InlineCode = append(b.format.InlineCode, b.write(text))
+	return b
+}
+
+// Pre appends code block; language may be empty
+func (b *Builder) Pre(text string, language string) *Builder {
+	b.format.Pre = append(b.format.Pre, Pre{Range: b.write(text), Language: language})
+	return b
+}
+
+func (b *Builder) Quote(text string) *Builder {
+	b.format.Quote = append(b.format.Quote, b.write(text))
+	return b
+}
+
+// OrderedList appends items separated by new lines
+func (b *Builder) OrderedList(items ...string) *Builder {
+	b.format.OrderedList = append(b.format.OrderedList, b.write(strings.Join(items, "\n")))
+	return b
+}
+
+// UnorderedList appends items separated by new lines
+func (b *Builder) UnorderedList(items ...string) *Builder {
+	b.format.UnorderedList = append(b.format.UnorderedList, b.write(strings.Join(items, "\n")))
+	return b
+}
+
+// String returns composed text
+func (b *Builder) String() string { return b.text.String() }
+
+// Format returns format of composed text
+func (b *Builder) Format() *Format {
+	f := b.format
+	return &f
+}
+
+func (b *Builder) write(text string) Range {
+	r := Range{Offset: b.length, Length: Len(text)}
+	b.text.WriteString(text)
+	b.length += r.Length
+	return r
+}
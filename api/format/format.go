@@ -0,0 +1,141 @@
+// Package format describes VK Teams "format" object: text styles given as ranges.
+// Offsets and lengths are measured in UTF-16 code units, as the API expects
+package format
+
+import (
+	"encoding/json"
+	"unicode/utf16"
+)
+
+// Range of text in UTF-16 code units
+type Range struct {
+	Offset int `json:"offset"`
+	Length int `json:"length"`
+}
+
+type Link struct {
+	Range
+	URL string `json:"url"`
+}
+
+type Pre struct {
+	Range
+	Language string `json:"lang,omitempty"`
+}
+
+type Format struct {
+	Bold          []Range `json:"bold,omitempty"`
+	Italic        []Range `json:"italic,omitempty"`
+	Underline     []Range `json:"underline,omitempty"`
+	Strikethrough []Range `json:"strikethrough,omitempty"`
+	Link          []Link  `json:"link,omitempty"`
+	Mention       []Range `json:"mention,omitempty"`
+	InlineCode    []Range `json:"inline_code,omitempty"`
+	Pre           []Pre   `json:"pre,omitempty"`
+	OrderedList   []Range `json:"ordered_list,omitempty"`
+	UnorderedList []Range `json:"unordered_list,omitempty"`
+	Quote         []Range `json:"quote,omitempty"`
+}
+
+// IsEmpty reports whether format has no styles
+func (f *Format) IsEmpty() bool {
+	return f == nil || len(f.Bold)+len(f.Italic)+len(f.Underline)+len(f.Strikethrough)+
+		len(f.Link)+len(f.Mention)+len(f.InlineCode)+len(f.Pre)+
+		len(f.OrderedList)+len(f.UnorderedList)+len(f.Quote) == 0
+}
+
+// String returns JSON representation of format, as it is sent to API
+func (f *Format) String() string {
+	bytes, _ := json.Marshal(f)
+	return string(bytes)
+}
+
+// Len returns length of text in UTF-16 code units
+func Len(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// Span converts byte indices of text [start, end) to Range
+func Span(text string, start int, end int) Range {
+	offset := Len(text[:start])
+	return Range{Offset: offset, Length: Len(text[start:end])}
+}
+
+// Bytes converts range to byte indices [start, end) of text.
+// Indices are clamped to text bounds
+func (r Range) Bytes(text string) (start int, end int) {
+	start, end = len(text), len(text)
+	units := 0
+	for i, c := range text {
+		if units >= r.Offset && start == len(text) {
+			start = i
+		}
+		if units >= r.Offset+r.Length {
+			end = i
+			break
+		}
+		units += utf16.RuneLen(c)
+	}
+	if start > end {
+		start = end
+	}
+	return start, end
+}
+
+// Extract returns text covered by range
+func (r Range) Extract(text string) string {
+	start, end := r.Bytes(text)
+	return text[start:end]
+}
+
+// Cut returns format of text part [offset, offset+length) (in UTF-16 code units).
+// Ranges are clipped to the part and shifted to its beginning
+func (f *Format) Cut(offset int, length int) *Format {
+	if f == nil {
+		return nil
+	}
+	cut := func(ranges []Range) []Range {
+		var result []Range
+		for _, r := range ranges {
+			if r, ok := r.cut(offset, length); ok {
+				result = append(result, r)
+			}
+		}
+		return result
+	}
+	part := &Format{
+		Bold:          cut(f.Bold),
+		Italic:        cut(f.Italic),
+		Underline:     cut(f.Underline),
+		Strikethrough: cut(f.Strikethrough),
+		Mention:       cut(f.Mention),
+		InlineCode:    cut(f.InlineCode),
+		OrderedList:   cut(f.OrderedList),
+		UnorderedList: cut(f.UnorderedList),
+		Quote:         cut(f.Quote),
+	}
+	for _, l := range f.Link {
+		if r, ok := l.Range.cut(offset, length); ok {
+			part.Link = append(part.Link, Link{Range: r, URL: l.URL})
+		}
+	}
+	for _, p := range f.Pre {
+		if r, ok := p.Range.cut(offset, length); ok {
+			part.Pre = append(part.Pre, Pre{Range: r, Language: p.Language})
+		}
+	}
+	return part
+}
+
+func (r Range) cut(offset int, length int) (Range, bool) {
+	start := max(r.Offset, offset)
+	end := min(r.Offset+r.Length, offset+length)
+	if start >= end {
+		return Range{}, false
+	}
+	return Range{Offset: start - offset, Length: end - start}, true
+}
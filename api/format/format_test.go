@@ -0,0 +1,77 @@
+package format_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/format"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLen(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "hello", want: 5},
+		{text: "привет", want: 6},
+		{text: "👍", want: 2},
+		{text: "a👍b", want: 4},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, format.Len(tt.text), tt.text)
+	}
+}
+
+func TestSpanExtract(t *testing.T) {
+	text := "👍 Привет, world"
+	start := len("👍 ")
+	end := start + len("Привет")
+	r := format.Span(text, start, end)
+	assert.Equal(t, format.Range{Offset: 3, Length: 6}, r)
+	assert.Equal(t, "Привет", r.Extract(text))
+	assert.Equal(t, "world", format.Range{Offset: 11, Length: 5}.Extract(text))
+	assert.Equal(t, "world", format.Range{Offset: 11, Length: 100}.Extract(text), "range is clamped")
+	assert.Equal(t, "", format.Range{Offset: 100, Length: 1}.Extract(text))
+}
+
+func TestBuilder(t *testing.T) {
+	b := &format.Builder{}
+	b.Text("🚀 ").Bold("Build").Text(" ").Link("logs", "https://ci.example").Text("\n").
+		Pre("go test ./...", "bash").Text("\n").UnorderedList("one", "two")
+	assert.Equal(t, "🚀 Build logs\ngo test ./...\none\ntwo", b.String())
+	f := b.Format()
+	assert.Equal(t, []format.Range{{Offset: 3, Length: 5}}, f.Bold)
+	assert.Equal(t, "Build", f.Bold[0].Extract(b.String()))
+	assert.Equal(t, "logs", f.Link[0].Extract(b.String()))
+	assert.Equal(t, "go test ./...", f.Pre[0].Extract(b.String()))
+	assert.Equal(t, "one\ntwo", f.UnorderedList[0].Extract(b.String()))
+	assert.False(t, f.IsEmpty())
+	assert.True(t, (&format.Format{}).IsEmpty())
+
+	decoded := &format.Format{}
+	assert.NoError(t, json.Unmarshal([]byte(f.String()), decoded))
+	assert.Equal(t, f, decoded)
+	assert.JSONEq(t, `{"offset":14,"length":13,"lang":"bash"}`, mustJSON(t, f.Pre[0]))
+}
+
+func TestFormatCut(t *testing.T) {
+	f := &format.Format{
+		Bold:   []format.Range{{Offset: 0, Length: 10}},
+		Italic: []format.Range{{Offset: 12, Length: 3}},
+		Link:   []format.Link{{Range: format.Range{Offset: 4, Length: 4}, URL: "https://example.com"}},
+	}
+	part := f.Cut(5, 10)
+	assert.Equal(t, []format.Range{{Offset: 0, Length: 5}}, part.Bold)
+	assert.Equal(t, []format.Range{{Offset: 7, Length: 3}}, part.Italic)
+	assert.Equal(t, []format.Link{{Range: format.Range{Offset: 0, Length: 3}, URL: "https://example.com"}}, part.Link)
+	assert.True(t, f.Cut(20, 5).IsEmpty())
+	assert.Nil(t, (*format.Format)(nil).Cut(0, 1))
+}
+
+func mustJSON(t *testing.T, v any) string {
+	bytes, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return string(bytes)
+}
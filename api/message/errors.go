@@ -1,5 +1,15 @@
 package message
 
-import "errors"
+import (
+	"errors"
 
-var ErrNotOk = errors.New("response status is not ok")
+	"github.com/s1em0nk3y/vkteams-bot/api/apierr"
+)
+
+var (
+	ErrNotOk           = apierr.ErrNotOk
+	ErrInvalidKeyboard = errors.New("invalid keyboard")
+)
+
+// APIError describes unsuccessful API response. It matches ErrNotOk with errors.Is
+type APIError = apierr.APIError
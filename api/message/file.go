@@ -1,72 +1,64 @@
 package message
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/apierr"
 )
 
 func (s *MessageService) sendFile(ctx context.Context, msg *FileMessage, path string) (msgID string, fileID string, err error) {
-	params := buildParams(&msg.Message)
+	params, err := buildParams(&msg.Message)
+	if err != nil {
+		return "", "", err
+	}
 	params.Set("caption", msg.Text)
 	if msg.FileID != "" {
 		params.Set("fileId", msg.FileID)
 	}
 	response := struct {
-		Id          string `json:"msgId"`
-		FileID      string `json:"fileId"`
-		Ok          bool   `json:"ok"`
-		Description string `json:"description"`
+		Id     string `json:"msgId"`
+		FileID string `json:"fileId"`
 	}{}
 
 	// Send POST; Upload file
+	var body io.Reader
+	var fileUpload *upload
 	if msg.Contents != nil {
-		buffer := &bytes.Buffer{}
-		part := multipart.NewWriter(buffer)
-		fileWriter, err := part.CreateFormFile("file", msg.Filename)
-		if err != nil {
-			return "", "", fmt.Errorf("unable to create file writer: %w", err)
-		}
-		_, err = io.Copy(fileWriter, msg.Contents)
-		if err != nil {
-			return "", "", fmt.Errorf("unable to copy file contents: %w", err)
-		}
-		part.Close()
-		req, err := s.client.PerformRequest(ctx, http.MethodPost, path, params, buffer)
-		if err != nil {
-			return "", "", err
-		}
-		req.Header.Set("Content-Type", part.FormDataContentType())
-		resp, err := s.client.Do(req)
-		if err != nil {
-			return "", "", fmt.Errorf("unable to upload file: %w", err)
-		}
-		defer resp.Body.Close()
-		if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-			return "", "", fmt.Errorf("unable to decode response: %w", err)
-		}
-		return response.Id, response.FileID, nil
+		fileUpload = newUpload(ctx, "file", msg.Filename, msg.Contents, msg.Progress)
+		defer fileUpload.Close()
+		body = fileUpload.reader
 	}
 
-	req, err := s.client.PerformRequest(ctx, http.MethodPost, path, params, nil)
+	req, err := s.client.PerformRequest(ctx, http.MethodPost, path, params, body)
 	if err != nil {
 		return "", "", err
 	}
+	if fileUpload != nil {
+		req.Header.Set("Content-Type", fileUpload.contentType)
+		if fileUpload.length >= 0 {
+			req.ContentLength = fileUpload.length
+		}
+	}
 
 	resp, err := s.client.Do(req)
 	if err != nil {
+		if fileUpload != nil {
+			if uploadErr := fileUpload.Close(); uploadErr != nil && !errors.Is(err, uploadErr) {
+				err = fmt.Errorf("%w: %w", uploadErr, err)
+			}
+		}
 		return "", "", fmt.Errorf("unable to upload file: %w", err)
 	}
 	defer resp.Body.Close()
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", "", fmt.Errorf("unable to decode response: %w", err)
+	if err = apierr.Decode(resp, &response); err != nil {
+		return "", "", err
 	}
-	if !response.Ok {
-		return "", "", fmt.Errorf("%w: %s", ErrNotOk, response.Description)
+	if response.FileID == "" {
+		response.FileID = msg.FileID
 	}
-	return response.Id, msg.FileID, nil
+	return response.Id, response.FileID, nil
 }
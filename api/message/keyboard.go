@@ -0,0 +1,149 @@
+package message
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Keyboard limits checked by KeyboardMarkup.Validate
+const (
+	MaxCallbackDataLength = 64 // bytes
+	MaxButtonsPerRow      = 8
+	MaxKeyboardRows       = 100
+)
+
+// CallbackButton returns button which sends callbackQuery event with data when pressed
+func CallbackButton(text string, data string) Button {
+	return Button{Text: text, Callback: data}
+}
+
+// URLButton returns button which opens link when pressed
+func URLButton(text string, link string) Button {
+	return Button{Text: text, URL: link}
+}
+
+// WithStyle returns copy of the button with given style
+func (b Button) WithStyle(style ButtonStyle) Button {
+	b.Style = style
+	return b
+}
+
+func (b Button) Primary() Button { return b.WithStyle(ButtonPrimary) }
+
+func (b Button) Attention() Button { return b.WithStyle(ButtonAttention) }
+
+// Validate checks that button has text, exactly one of URL and Callback
+// and a known style
+func (b Button) Validate() error {
+	if b.Text == "" {
+		return fmt.Errorf("%w: empty text", ErrInvalidKeyboard)
+	}
+	switch {
+	case b.URL == "" && b.Callback == "":
+		return fmt.Errorf("%w: button %q has neither url nor callback data", ErrInvalidKeyboard, b.Text)
+	case b.URL != "" && b.Callback != "":
+		return fmt.Errorf("%w: button %q has both url and callback data", ErrInvalidKeyboard, b.Text)
+	case len(b.Callback) > MaxCallbackDataLength:
+		return fmt.Errorf("%w: button %q callback data is %d bytes, limit is %d",
+			ErrInvalidKeyboard, b.Text, len(b.Callback), MaxCallbackDataLength)
+	}
+	if b.URL != "" {
+		if u, err := url.Parse(b.URL); err != nil || !u.IsAbs() {
+			return fmt.Errorf("%w: button %q has invalid url %q", ErrInvalidKeyboard, b.Text, b.URL)
+		}
+	}
+	switch b.Style {
+	case "", ButtonBase, ButtonPrimary, ButtonAttention:
+	default:
+		return fmt.Errorf("%w: button %q has unknown style %q", ErrInvalidKeyboard, b.Text, b.Style)
+	}
+	return nil
+}
+
+// Validate checks row and button counts and every button of the keyboard.
+// It is called before the keyboard is sent
+func (k KeyboardMarkup) Validate() error {
+	if len(k) > MaxKeyboardRows {
+		return fmt.Errorf("%w: %d rows, limit is %d", ErrInvalidKeyboard, len(k), MaxKeyboardRows)
+	}
+	for i, row := range k {
+		if len(row) == 0 {
+			return fmt.Errorf("%w: row %d is empty", ErrInvalidKeyboard, i+1)
+		}
+		if len(row) > MaxButtonsPerRow {
+			return fmt.Errorf("%w: row %d has %d buttons, limit is %d", ErrInvalidKeyboard, i+1, len(row), MaxButtonsPerRow)
+		}
+		for j, b := range row {
+			if err := b.Validate(); err != nil {
+				return fmt.Errorf("row %d, button %d: %w", i+1, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// KeyboardBuilder composes inline keyboard row by row:
+//
+//	kb := message.NewKeyboard().
+//		Row(message.CallbackButton("Yes", "yes").Primary(), message.CallbackButton("No", "no")).
+//		Row(message.URLButton("Docs", "https://example.com"))
+//	msg.KeyboardMarkup = kb.Markup()
+type KeyboardBuilder struct {
+	rows KeyboardMarkup
+}
+
+func NewKeyboard() *KeyboardBuilder { return &KeyboardBuilder{} }
+
+// Row appends one row of buttons. Empty rows are skipped
+func (k *KeyboardBuilder) Row(buttons ...Button) *KeyboardBuilder {
+	if len(buttons) > 0 {
+		k.rows = append(k.rows, append([]Button(nil), buttons...))
+	}
+	return k
+}
+
+// Column appends each button as a separate row
+func (k *KeyboardBuilder) Column(buttons ...Button) *KeyboardBuilder {
+	for _, b := range buttons {
+		k.Row(b)
+	}
+	return k
+}
+
+// Grid lays buttons out in rows of given number of columns; the last row may be shorter
+func (k *KeyboardBuilder) Grid(columns int, buttons ...Button) *KeyboardBuilder {
+	if columns < 1 {
+		columns = 1
+	}
+	for len(buttons) > 0 {
+		n := min(columns, len(buttons))
+		k.Row(buttons[:n]...)
+		buttons = buttons[n:]
+	}
+	return k
+}
+
+// Style sets style for all the buttons added so far
+func (k *KeyboardBuilder) Style(style ButtonStyle) *KeyboardBuilder {
+	for _, row := range k.rows {
+		for i := range row {
+			row[i].Style = style
+		}
+	}
+	return k
+}
+
+// Markup returns composed keyboard; nil if no buttons were added
+func (k *KeyboardBuilder) Markup() *KeyboardMarkup {
+	if len(k.rows) == 0 {
+		return nil
+	}
+	rows := make(KeyboardMarkup, len(k.rows))
+	for i, row := range k.rows {
+		rows[i] = append([]Button(nil), row...)
+	}
+	return &rows
+}
+
+// Validate checks composed keyboard (see KeyboardMarkup.Validate)
+func (k *KeyboardBuilder) Validate() error { return k.rows.Validate() }
@@ -3,10 +3,12 @@ package message
 //go:generate gotests -exported -template testify -w ./message.go
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/apierr"
+	"github.com/s1em0nk3y/vkteams-bot/api/format"
 )
 
 type MessageService struct {
@@ -17,7 +19,10 @@ func New(cli Client) *MessageService { return &MessageService{cli} }
 
 // /messages/sendText (Get)
 func (s *MessageService) SendText(ctx context.Context, msg *Message) (msgID string, err error) {
-	params := buildParams(msg)
+	params, err := buildParams(msg)
+	if err != nil {
+		return "", err
+	}
 	params.Set("text", msg.Text)
 	req, err := s.client.PerformRequest(ctx, http.MethodGet, "/messages/sendText", params, nil)
 	if err != nil {
@@ -31,20 +36,49 @@ func (s *MessageService) SendText(ctx context.Context, msg *Message) (msgID stri
 	defer resp.Body.Close()
 
 	response := struct {
-		Id          string `json:"msgId"`
-		Ok          bool   `json:"ok"`
-		Description string `json:"description"`
+		Id string `json:"msgId"`
 	}{}
-
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("unable to decode response: %w", err)
-	}
-	if !response.Ok {
-		return "", fmt.Errorf("%w: %s", ErrNotOk, response.Description)
+	if err = apierr.Decode(resp, &response); err != nil {
+		return "", err
 	}
 	return response.Id, nil
 }
 
+// SendLongText sends text longer than MaxTextLength as several messages (see SplitText).
+// The first part replies to msg.ReplyMsgID, the next ones reply to the first part.
+// Keyboard is attached to the last part. Returns IDs of sent parts;
+// on error IDs of parts sent before it are returned too
+func (s *MessageService) SendLongText(ctx context.Context, msg *Message) (msgIDs []string, err error) {
+	mode := msg.ParseMode
+	if !msg.MessageFormat.IsEmpty() {
+		mode = ParseModeUnknown
+	}
+	chunks := splitText(msg.Text, mode, MaxTextLength)
+	if len(chunks) == 0 {
+		chunks = []chunk{{text: msg.Text}}
+	}
+	for i, c := range chunks {
+		part := *msg
+		part.Text = c.text
+		if !msg.MessageFormat.IsEmpty() {
+			part.MessageFormat = msg.MessageFormat.Cut(format.Len(msg.Text[:c.start]), format.Len(c.text))
+		}
+		if i > 0 {
+			part.ReplyMsgID = msgIDs[0]
+			part.ForwardChatID, part.ForwardMsgID = "", ""
+		}
+		if i < len(chunks)-1 {
+			part.KeyboardMarkup = nil
+		}
+		msgID, err := s.SendText(ctx, &part)
+		if err != nil {
+			return msgIDs, fmt.Errorf("unable to send part %d of %d: %w", i+1, len(chunks), err)
+		}
+		msgIDs = append(msgIDs, msgID)
+	}
+	return msgIDs, nil
+}
+
 // /messages/sendFile (Get/Post)
 func (s *MessageService) SendFile(ctx context.Context, msg *FileMessage) (msgID string, fileID string, err error) {
 	return s.sendFile(ctx, msg, "/messages/sendFile")
@@ -57,7 +91,10 @@ func (s *MessageService) SendVoice(ctx context.Context, msg *FileMessage) (msgID
 
 // /messages/editText
 func (s *MessageService) EditMessage(ctx context.Context, msg *EditMessage) error {
-	params := buildParams(&msg.Message)
+	params, err := buildParams(&msg.Message)
+	if err != nil {
+		return err
+	}
 	params.Set("msgId", msg.MessageToEditID)
 	params.Set("text", msg.Text)
 	req, err := s.client.PerformRequest(ctx, http.MethodGet, "/messages/editText", params, nil)
@@ -70,17 +107,7 @@ func (s *MessageService) EditMessage(ctx context.Context, msg *EditMessage) erro
 		return err
 	}
 	defer resp.Body.Close()
-	response := struct {
-		Ok          bool   `json:"ok"`
-		Description string `json:"description"`
-	}{}
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("unable to decode response: %w", err)
-	}
-	if !response.Ok {
-		return fmt.Errorf("%w: %s", ErrNotOk, response.Description)
-	}
-	return nil
+	return apierr.Decode(resp, nil)
 }
 
 // /messages/deleteMessage
@@ -99,18 +126,8 @@ func (s *MessageService) DeleteMessages(ctx context.Context, msg *DeleteMessage)
 	if err != nil {
 		return err
 	}
-
-	response := struct {
-		Ok          bool   `json:"ok"`
-		Description string `json:"description"`
-	}{}
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("unable to decode response: %w", err)
-	}
-	if !response.Ok {
-		return fmt.Errorf("%w: %s", ErrNotOk, response.Description)
-	}
-	return nil
+	defer resp.Body.Close()
+	return apierr.Decode(resp, nil)
 }
 
 // /messages/answerCallbackQuery
@@ -137,15 +154,6 @@ func (s *MessageService) AnswerCallback(ctx context.Context, answer *AnswerCallb
 	if err != nil {
 		return err
 	}
-	response := struct {
-		Ok          bool   `json:"ok"`
-		Description string `json:"description"`
-	}{}
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return fmt.Errorf("unable to decode response: %w", err)
-	}
-	if !response.Ok {
-		return fmt.Errorf("%w: %s", ErrNotOk, response.Description)
-	}
-	return nil
+	defer resp.Body.Close()
+	return apierr.Decode(resp, nil)
 }
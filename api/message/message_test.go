@@ -4,18 +4,27 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
 	"github.com/s1em0nk3y/vkteams-bot"
+	"github.com/s1em0nk3y/vkteams-bot/api/format"
 	"github.com/s1em0nk3y/vkteams-bot/api/message"
+	"github.com/s1em0nk3y/vkteams-bot/vkteamstest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var TestCfg = struct {
@@ -41,7 +50,10 @@ var testBot *vkteams.Bot
 func TestMain(m *testing.M) {
 	godotenv.Load("../../.env")
 	if err := env.Parse(&TestCfg); err != nil {
-		log.Fatal(err)
+		log.Printf("%s; running against fake API", err)
+		server, cleanup := useFakeAPI()
+		defer server.Close()
+		defer cleanup()
 	}
 	if !TestCfg.Proxy {
 		httpClient.Transport.(*http.Transport).Proxy = nil
@@ -56,6 +68,30 @@ func TestMain(m *testing.M) {
 	m.Run()
 }
 
+// useFakeAPI points TestCfg to in-process fake API with prepared chat, message and files
+func useFakeAPI() (server *vkteamstest.Server, cleanup func()) {
+	server = vkteamstest.NewServer()
+	voice := []byte("voice message")
+	TestCfg.Token, TestCfg.URL = server.Token, server.URL
+	TestCfg.ChatID = "user@example.com"
+	TestCfg.MessageID = server.AddMessage(TestCfg.ChatID, vkteamstest.DefaultBot.UserID, "Message of the bot").ID
+	TestCfg.FileID = server.AddFile("file.txt", "file", []byte("some text"))
+	TestCfg.VoiceFileID = server.AddFile("voice.aac", "voice", voice)
+
+	file, err := os.CreateTemp("", "voice*.aac")
+	if err != nil {
+		log.Fatal(err)
+	}
+	file.Write(voice)
+	file.Close()
+	// Tests open voice file relative to repository root
+	root, _ := filepath.Abs("../..")
+	if TestCfg.VoiceFilePath, err = filepath.Rel(root, file.Name()); err != nil {
+		log.Fatal(err)
+	}
+	return server, func() { os.Remove(file.Name()) }
+}
+
 func TestMessageService_SendText(t *testing.T) {
 	type args struct {
 		ctx context.Context
@@ -296,6 +332,350 @@ func TestMessageService_SendFile(t *testing.T) {
 	}
 }
 
+func TestMessageService_SendFile_Upload(t *testing.T) {
+	tests := []struct {
+		name       string
+		contents   func() io.Reader
+		wantLength bool
+	}{
+		{
+			name:       "Known length (bytes.Buffer)",
+			contents:   func() io.Reader { return bytes.NewBufferString("file contents") },
+			wantLength: true,
+		},
+		{
+			name: "Known length (os.File)",
+			contents: func() io.Reader {
+				f, err := os.CreateTemp(t.TempDir(), "upload")
+				require.NoError(t, err)
+				t.Cleanup(func() { f.Close() })
+				f.WriteString("file contents")
+				f.Seek(0, io.SeekStart)
+				return f
+			},
+			wantLength: true,
+		},
+		{
+			name:     "Unknown length",
+			contents: func() io.Reader { return io.MultiReader(strings.NewReader("file "), strings.NewReader("contents")) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.wantLength {
+					assert.Greater(t, r.ContentLength, int64(0))
+				} else {
+					assert.Equal(t, int64(-1), r.ContentLength)
+				}
+				file, header, err := r.FormFile("file")
+				if !assert.NoError(t, err) {
+					return
+				}
+				contents, _ := io.ReadAll(file)
+				assert.Equal(t, "file contents", string(contents))
+				assert.Equal(t, "upload.txt", header.Filename)
+				w.Write([]byte(`{"ok":true,"msgId":"1","fileId":"file1"}`))
+			}))
+			defer server.Close()
+			s := message.New(vkteams.New("token", vkteams.WithApiURL(server.URL)))
+			gotMsgID, gotFileID, err := s.SendFile(context.Background(), &message.FileMessage{
+				Message:  message.Message{ChatID: "chat"},
+				Filename: "upload.txt",
+				Contents: tt.contents(),
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, "1", gotMsgID)
+			assert.Equal(t, "file1", gotFileID)
+		})
+	}
+}
+
+func TestMessageService_SendFile_Progress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(`{"ok":true,"msgId":"1","fileId":"file1"}`))
+	}))
+	defer server.Close()
+	s := message.New(vkteams.New("token", vkteams.WithApiURL(server.URL)))
+	contents := bytes.Repeat([]byte("a"), 100*1024)
+	var lastSent, lastTotal int64
+	_, _, err := s.SendFile(context.Background(), &message.FileMessage{
+		Message:  message.Message{ChatID: "chat"},
+		Filename: "upload.txt",
+		Contents: bytes.NewReader(contents),
+		Progress: func(sent, total int64) {
+			assert.GreaterOrEqual(t, sent, lastSent)
+			lastSent, lastTotal = sent, total
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), lastSent)
+	assert.Equal(t, int64(len(contents)), lastTotal)
+}
+
+func TestMessageService_SendText_Format(t *testing.T) {
+	b := &format.Builder{}
+	b.Text("👍 ").Bold("bold")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "👍 bold", r.URL.Query().Get("text"))
+		assert.JSONEq(t, `{"bold":[{"offset":3,"length":4}]}`, r.URL.Query().Get("format"))
+		assert.False(t, r.URL.Query().Has("parseMode"))
+		w.Write([]byte(`{"ok":true,"msgId":"1"}`))
+	}))
+	defer server.Close()
+	s := message.New(vkteams.New("token", vkteams.WithApiURL(server.URL)))
+	_, err := s.SendText(context.Background(), &message.Message{
+		ChatID:        "chat",
+		Text:          b.String(),
+		MessageFormat: b.Format(),
+	})
+	assert.NoError(t, err)
+}
+
+func TestTextBuilder(t *testing.T) {
+	build := func(mode message.ParseMode) *message.TextBuilder {
+		return message.NewText(mode).
+			Text("a<b>_c ").Bold("x*y").Text(" ").Link("docs [1]", "https://example.com/a_(b)").
+			Pre("if a < b {}", "go").
+			Quote("line 1\nline <2>").
+			UnorderedList("one.", "two").
+			Text("by ").Mention("user@example.com")
+	}
+	tests := []struct {
+		name string
+		mode message.ParseMode
+		want string
+	}{
+		{
+			name: "HTML",
+			mode: message.ParseModeHTML,
+			want: "a&lt;b&gt;_c <b>x*y</b> <a href=\"https://example.com/a_(b)\">docs [1]</a>\n" +
+				"<pre><code class=\"go\">if a &lt; b {}</code></pre>\n" +
+				"<blockquote>line 1\nline &lt;2&gt;</blockquote>\n" +
+				"<ul><li>one.</li><li>two</li></ul>\n" +
+				"by @[user@example.com]",
+		},
+		{
+			name: "MarkdownV2",
+			mode: message.ParseModeMarkdown,
+			want: "a<b\\>\\_c *x\\*y* [docs \\[1\\]](https://example.com/a_(b\\))\n" +
+				"```go\nif a < b {}\n```\n" +
+				">line 1\n>line <2\\>\n" +
+				"- one\\.\n- two\n" +
+				"by @[user@example.com]",
+		},
+		{
+			name: "Plain",
+			mode: message.ParseModeUnknown,
+			want: "a<b>_c x*y docs [1] (https://example.com/a_(b))\n" +
+				"if a < b {}\n" +
+				"line 1\nline <2>\n" +
+				"- one.\n- two\n" +
+				"by @[user@example.com]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &message.Message{}
+			build(tt.mode).Apply(msg)
+			assert.Equal(t, tt.want, msg.Text)
+			assert.Equal(t, tt.mode, msg.ParseMode)
+		})
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		mode  message.ParseMode
+		limit int
+		want  []string
+	}{
+		{
+			name:  "Short text",
+			text:  "short",
+			limit: 10,
+			want:  []string{"short"},
+		},
+		{
+			name:  "Paragraphs",
+			text:  "first paragraph\n\nsecond one",
+			limit: 20,
+			want:  []string{"first paragraph", "second one"},
+		},
+		{
+			name:  "Lines before words",
+			text:  "one two\nthree four five",
+			limit: 16,
+			want:  []string{"one two", "three four five"},
+		},
+		{
+			name:  "Words",
+			text:  "one two three four",
+			limit: 10,
+			want:  []string{"one two", "three four"},
+		},
+		{
+			name:  "Long word",
+			text:  "абвгдеёжзи",
+			limit: 4,
+			want:  []string{"абвг", "деёж", "зи"},
+		},
+		{
+			name:  "HTML tags are reopened",
+			text:  "<b>bold <i>text &amp; more</i></b> tail",
+			mode:  message.ParseModeHTML,
+			limit: 24,
+			want:  []string{"<b>bold <i>text</i></b>", "<b><i>&amp; more</i></b>", "tail"},
+		},
+		{
+			name:  "Markdown code block is reopened",
+			text:  "```go\nline1\nline2\n```",
+			mode:  message.ParseModeMarkdown,
+			limit: 20,
+			want:  []string{"```go\nline1\n\n```", "```go\nline2\n```"},
+		},
+		{
+			name:  "Markdown styles are reopened",
+			text:  "*bold \\* text* end",
+			mode:  message.ParseModeMarkdown,
+			limit: 10,
+			want:  []string{"*bold \\**", "*text* end"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := message.SplitText(tt.text, tt.mode, tt.limit)
+			assert.Equal(t, tt.want, got)
+			for _, part := range got {
+				assert.LessOrEqual(t, len([]rune(part)), tt.limit)
+			}
+		})
+	}
+}
+
+func TestMessageService_SendLongText(t *testing.T) {
+	var sent []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.URL.Query())
+		fmt.Fprintf(w, `{"ok":true,"msgId":"%d"}`, len(sent))
+	}))
+	defer server.Close()
+	s := message.New(vkteams.New("token", vkteams.WithApiURL(server.URL)))
+	paragraph := strings.Repeat("word ", message.MaxTextLength/5) + "\n\n"
+	ids, err := s.SendLongText(context.Background(), &message.Message{
+		ChatID:         "chat",
+		Text:           paragraph + paragraph + "end",
+		ReplyMsgID:     "original",
+		KeyboardMarkup: &message.KeyboardMarkup{{{Text: "OK", Callback: "ok"}}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+	require.Len(t, sent, 3)
+	assert.Equal(t, "original", sent[0].Get("replyMsgId"))
+	assert.Equal(t, "1", sent[1].Get("replyMsgId"))
+	assert.Equal(t, "1", sent[2].Get("replyMsgId"))
+	assert.Equal(t, "end", sent[2].Get("text"))
+	assert.False(t, sent[0].Has("inlineKeyboardMarkup"))
+	assert.True(t, sent[2].Has("inlineKeyboardMarkup"))
+}
+
+func TestKeyboardBuilder(t *testing.T) {
+	yes := message.CallbackButton("Yes", "yes")
+	no := message.CallbackButton("No", "no")
+	docs := message.URLButton("Docs", "https://example.com")
+	kb := message.NewKeyboard().
+		Grid(2, yes, no, docs).
+		Style(message.ButtonPrimary).
+		Column(yes.Attention(), no).
+		Row()
+	assert.NoError(t, kb.Validate())
+	assert.Equal(t, &message.KeyboardMarkup{
+		{yes.Primary(), no.Primary()},
+		{docs.Primary()},
+		{yes.Attention()},
+		{no},
+	}, kb.Markup())
+	assert.Nil(t, message.NewKeyboard().Markup())
+}
+
+func TestKeyboardMarkup_Validate(t *testing.T) {
+	ok := message.CallbackButton("OK", "ok")
+	tests := []struct {
+		name     string
+		keyboard message.KeyboardMarkup
+		wantErr  string
+	}{
+		{name: "Valid", keyboard: message.KeyboardMarkup{{ok, message.URLButton("Docs", "https://example.com")}}},
+		{name: "Empty", keyboard: message.KeyboardMarkup{}},
+		{name: "Empty row", keyboard: message.KeyboardMarkup{{ok}, {}}, wantErr: "row 2 is empty"},
+		{name: "No text", keyboard: message.KeyboardMarkup{{{Callback: "ok"}}}, wantErr: "row 1, button 1: invalid keyboard: empty text"},
+		{name: "No action", keyboard: message.KeyboardMarkup{{{Text: "OK"}}}, wantErr: "neither url nor callback data"},
+		{name: "Both actions", keyboard: message.KeyboardMarkup{{{Text: "OK", Callback: "ok", URL: "https://example.com"}}}, wantErr: "both url and callback data"},
+		{
+			name:     "Long callback",
+			keyboard: message.KeyboardMarkup{{ok, message.CallbackButton("Long", strings.Repeat("x", message.MaxCallbackDataLength+1))}},
+			wantErr:  "row 1, button 2: invalid keyboard: button \"Long\" callback data is 65 bytes, limit is 64",
+		},
+		{name: "Relative url", keyboard: message.KeyboardMarkup{{message.URLButton("Docs", "/docs")}}, wantErr: "invalid url"},
+		{name: "Unknown style", keyboard: message.KeyboardMarkup{{ok.WithStyle("red")}}, wantErr: "unknown style"},
+		{
+			name:     "Too many buttons",
+			keyboard: message.KeyboardMarkup{make([]message.Button, message.MaxButtonsPerRow+1)},
+			wantErr:  "row 1 has 9 buttons",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.keyboard.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, message.ErrInvalidKeyboard)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestMessageService_SendText_InvalidKeyboard(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		io.WriteString(w, `{"ok":true,"msgId":"1"}`)
+	}))
+	defer server.Close()
+	s := message.New(vkteams.New("token", vkteams.WithApiURL(server.URL)))
+	_, err := s.SendText(context.Background(), &message.Message{
+		ChatID:         "chat",
+		Text:           "text",
+		KeyboardMarkup: &message.KeyboardMarkup{{{Text: "No action"}}},
+	})
+	assert.ErrorIs(t, err, message.ErrInvalidKeyboard)
+	assert.False(t, called, "request must not be sent")
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("disk failure") }
+
+func TestMessageService_SendFile_UploadError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+	s := message.New(vkteams.New("token", vkteams.WithApiURL(server.URL)))
+	_, _, err := s.SendFile(context.Background(), &message.FileMessage{
+		Message:  message.Message{ChatID: "chat"},
+		Filename: "upload.txt",
+		Contents: failingReader{},
+	})
+	assert.ErrorContains(t, err, "disk failure")
+}
+
 func TestMessageService_SendVoice(t *testing.T) {
 	type args struct {
 		ctx context.Context
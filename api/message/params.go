@@ -5,7 +5,7 @@ import (
 	"net/url"
 )
 
-func buildParams(msg *Message) url.Values {
+func buildParams(msg *Message) (url.Values, error) {
 	params := url.Values{
 		"chatId": {msg.ChatID},
 	}
@@ -17,11 +17,17 @@ func buildParams(msg *Message) url.Values {
 		params.Set("forwardChatId", msg.ForwardChatID)
 	}
 	if msg.KeyboardMarkup != nil {
+		if err := msg.KeyboardMarkup.Validate(); err != nil {
+			return nil, err
+		}
 		bytes, _ := json.Marshal(msg.KeyboardMarkup)
 		params.Set("inlineKeyboardMarkup", string(bytes))
 	}
+	if !msg.MessageFormat.IsEmpty() {
+		params.Set("format", msg.MessageFormat.String())
+	}
 	if msg.ParseMode != ParseModeUnknown {
 		params.Set("parseMode", msg.ParseMode.String())
 	}
-	return params
+	return params, nil
 }
@@ -0,0 +1,307 @@
+package message
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxTextLength is the max number of characters in message text accepted by API
+const MaxTextLength = 4096
+
+// Break points, from the most preferred one
+const (
+	breakParagraph = iota
+	breakLine
+	breakWord
+	breakAny
+)
+
+// chunk is a part of text: markup reopened from previous chunk, text[start:end]
+// and markup closed before the next chunk
+type chunk struct {
+	text       string
+	start, end int
+}
+
+// SplitText splits text into parts of at most limit characters.
+// Text is split on paragraph, line or word boundaries when possible.
+// Markup of given parse mode is kept valid: HTML tags, Markdown styles
+// and code blocks open at the split point are closed and reopened in the next part
+func SplitText(text string, mode ParseMode, limit int) []string {
+	chunks := splitText(text, mode, limit)
+	parts := make([]string, len(chunks))
+	for i, c := range chunks {
+		parts[i] = c.text
+	}
+	return parts
+}
+
+// candidate is a possible end of chunk
+type candidate struct {
+	pos   int
+	state markupState
+}
+
+func splitText(text string, mode ParseMode, limit int) []chunk {
+	if limit <= 0 {
+		limit = MaxTextLength
+	}
+	var chunks []chunk
+	state := newMarkupState(mode)
+	pos := 0
+	for pos < len(text) {
+		prefix := state.reopen()
+		if utf8.RuneCountInString(prefix)+utf8.RuneCountInString(text[pos:]) <= limit {
+			chunks = append(chunks, chunk{text: prefix + text[pos:], start: pos, end: len(text)})
+			break
+		}
+		best := [breakAny + 1]*candidate{}
+		length := utf8.RuneCountInString(prefix)
+		cur := state.clone()
+		for i := pos; i < len(text); {
+			n := atomLength(text[i:], cur)
+			next := cur.clone()
+			next.consume(text[i : i+n])
+			length += utf8.RuneCountInString(text[i : i+n])
+			if length+utf8.RuneCountInString(next.close()) > limit && best[breakAny] != nil {
+				break
+			}
+			i += n
+			cur = next
+			kind := breakAny
+			switch {
+			case strings.HasSuffix(text[pos:i], "\n\n"):
+				kind = breakParagraph
+			case strings.HasSuffix(text[pos:i], "\n"):
+				kind = breakLine
+			case strings.HasSuffix(text[pos:i], " "):
+				kind = breakWord
+			}
+			c := &candidate{pos: i, state: cur}
+			for k := kind; k <= breakAny; k++ {
+				best[k] = c
+			}
+		}
+		// Prefer nicer break unless it makes chunk too short
+		end := best[breakAny]
+		for _, c := range best {
+			if c != nil && c.pos-pos >= (end.pos-pos)/2 {
+				end = c
+				break
+			}
+		}
+		body := text[pos:end.pos]
+		if !end.state.literal() {
+			body = strings.TrimRight(body, " \n")
+		}
+		if body != "" {
+			chunks = append(chunks, chunk{
+				text:  prefix + body + end.state.close(),
+				start: pos,
+				end:   pos + len(body),
+			})
+		}
+		pos = end.pos
+		state = end.state
+		if !state.literal() {
+			for pos < len(text) && (text[pos] == ' ' || text[pos] == '\n') {
+				pos++
+			}
+		}
+	}
+	return chunks
+}
+
+// atomLength returns length of the smallest piece of text which must not be split
+func atomLength(text string, state markupState) int {
+	switch state.mode {
+	case ParseModeHTML:
+		if text[0] == '<' {
+			if i := strings.IndexByte(text, '>'); i > 0 {
+				return i + 1
+			}
+		}
+		if text[0] == '&' {
+			if i := strings.IndexByte(text, ';'); i > 0 && i <= 10 {
+				return i + 1
+			}
+		}
+	case ParseModeMarkdown:
+		if text[0] == '\\' && len(text) > 1 {
+			_, n := utf8.DecodeRuneInString(text[1:])
+			return 1 + n
+		}
+		if strings.HasPrefix(text, "```") {
+			return 3
+		}
+		if state.fence || state.code {
+			break
+		}
+		if strings.HasPrefix(text, "__") {
+			return 2
+		}
+		if text[0] == '[' {
+			if n := markdownLinkLength(text); n > 0 {
+				return n
+			}
+		}
+	}
+	_, n := utf8.DecodeRuneInString(text)
+	return n
+}
+
+// markdownLinkLength returns length of [text](url) at the beginning of text or 0
+func markdownLinkLength(text string) int {
+	closing := func(s string, c byte) int {
+		for i := 0; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+			if s[i] == c {
+				return i
+			}
+		}
+		return -1
+	}
+	i := closing(text[1:], ']')
+	if i < 0 || !strings.HasPrefix(text[i+2:], "(") {
+		return 0
+	}
+	j := closing(text[i+3:], ')')
+	if j < 0 {
+		return 0
+	}
+	return i + 3 + j + 1
+}
+
+// markupState tracks markup open at some point of text
+type markupState struct {
+	mode ParseMode
+	// HTML: open tags
+	tags []string
+	// Markdown: open style markers, inline code and code block with its language
+	markers  []string
+	code     bool
+	fence    bool
+	fenceTag string
+}
+
+func newMarkupState(mode ParseMode) markupState { return markupState{mode: mode} }
+
+func (s markupState) clone() markupState {
+	s.tags = append([]string(nil), s.tags...)
+	s.markers = append([]string(nil), s.markers...)
+	return s
+}
+
+// literal reports whether whitespace is significant at this point
+func (s markupState) literal() bool {
+	if s.fence || s.code {
+		return true
+	}
+	for _, tag := range s.tags {
+		if name := tagName(tag); name == "pre" || name == "code" {
+			return true
+		}
+	}
+	return false
+}
+
+// consume updates state with atom of text
+func (s *markupState) consume(atom string) {
+	switch s.mode {
+	case ParseModeHTML:
+		if !strings.HasPrefix(atom, "<") || !strings.HasSuffix(atom, ">") || strings.HasSuffix(atom, "/>") {
+			return
+		}
+		name := tagName(atom)
+		if strings.HasPrefix(atom, "</") {
+			for i := len(s.tags) - 1; i >= 0; i-- {
+				if tagName(s.tags[i]) == name {
+					s.tags = s.tags[:i]
+					break
+				}
+			}
+			return
+		}
+		if name != "br" {
+			s.tags = append(s.tags, atom)
+		}
+	case ParseModeMarkdown:
+		switch {
+		case s.fence:
+			if atom == "```" {
+				s.fence = false
+			} else if s.fenceTag != "" && !strings.Contains(s.fenceTag, "\n") {
+				s.fenceTag += atom
+			}
+		case atom == "```":
+			s.fence = true
+			s.fenceTag = "```"
+		case atom == "`":
+			s.code = !s.code
+		case s.code:
+		case atom == "*" || atom == "_" || atom == "__" || atom == "~":
+			for i := len(s.markers) - 1; i >= 0; i-- {
+				if s.markers[i] == atom {
+					s.markers = append(s.markers[:i], s.markers[i+1:]...)
+					return
+				}
+			}
+			s.markers = append(s.markers, atom)
+		}
+	}
+}
+
+// close returns markup closing everything open
+func (s markupState) close() string {
+	b := strings.Builder{}
+	switch s.mode {
+	case ParseModeHTML:
+		for i := len(s.tags) - 1; i >= 0; i-- {
+			b.WriteString("</" + tagName(s.tags[i]) + ">")
+		}
+	case ParseModeMarkdown:
+		if s.fence {
+			return "\n```"
+		}
+		if s.code {
+			b.WriteString("`")
+		}
+		for i := len(s.markers) - 1; i >= 0; i-- {
+			b.WriteString(s.markers[i])
+		}
+	}
+	return b.String()
+}
+
+// reopen returns markup opening again everything closed by close
+func (s markupState) reopen() string {
+	switch s.mode {
+	case ParseModeHTML:
+		return strings.Join(s.tags, "")
+	case ParseModeMarkdown:
+		if s.fence {
+			tag := s.fenceTag
+			if !strings.HasSuffix(tag, "\n") {
+				tag += "\n"
+			}
+			return tag
+		}
+		opening := strings.Join(s.markers, "")
+		if s.code {
+			opening += "`"
+		}
+		return opening
+	}
+	return ""
+}
+
+func tagName(tag string) string {
+	name := strings.TrimLeft(tag, "</")
+	if i := strings.IndexAny(name, " \t\n>/"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ToLower(name)
+}
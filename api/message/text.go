@@ -0,0 +1,226 @@
+package message
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	htmlEscaper = strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+		`"`, "&quot;",
+	)
+	markdownEscaper = newEscaper("\\_*[]()~`>#+-=|{}.!")
+	codeEscaper     = newEscaper("\\`")
+	urlEscaper      = newEscaper("\\)")
+)
+
+func newEscaper(chars string) *strings.Replacer {
+	pairs := make([]string, 0, len(chars)*2)
+	for _, c := range chars {
+		pairs = append(pairs, string(c), "\\"+string(c))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// EscapeHTML escapes text to be shown as is with ParseModeHTML
+func EscapeHTML(text string) string { return htmlEscaper.Replace(text) }
+
+// EscapeMarkdown escapes text to be shown as is with ParseModeMarkdown
+func EscapeMarkdown(text string) string { return markdownEscaper.Replace(text) }
+
+// Escape escapes text for given parse mode. Text is not changed for ParseModeUnknown
+func Escape(mode ParseMode, text string) string {
+	switch mode {
+	case ParseModeHTML:
+		return EscapeHTML(text)
+	case ParseModeMarkdown:
+		return EscapeMarkdown(text)
+	default:
+		return text
+	}
+}
+
+// TextBuilder composes message text for given parse mode. All the text passed to it
+// is escaped, so user input can not break markup:
+//
+//	text := message.NewText(message.ParseModeHTML).Text("You said: ").Italic(ev.Text)
+//	text.Apply(msg)
+//
+// With ParseModeUnknown styles are omitted and text is kept as is
+type TextBuilder struct {
+	mode ParseMode
+	b    strings.Builder
+	// Line break after block element, written before the next text
+	lineBreak bool
+}
+
+func NewText(mode ParseMode) *TextBuilder { return &TextBuilder{mode: mode} }
+
+// Text appends plain text
+func (t *TextBuilder) Text(text string) *TextBuilder {
+	t.write(Escape(t.mode, text))
+	return t
+}
+
+// Raw appends text without escaping; it must be valid markup for the parse mode
+func (t *TextBuilder) Raw(markup string) *TextBuilder {
+	t.write(markup)
+	return t
+}
+
+func (t *TextBuilder) Bold(text string) *TextBuilder { return t.wrap(text, "<b>", "</b>", "*") }
+
+func (t *TextBuilder) Italic(text string) *TextBuilder { return t.wrap(text, "<i>", "</i>", "_") }
+
+func (t *TextBuilder) Underline(text string) *TextBuilder { return t.wrap(text, "<u>", "</u>", "__") }
+
+func (t *TextBuilder) Strikethrough(text string) *TextBuilder {
+	return t.wrap(text, "<s>", "</s>", "~")
+}
+
+// Code appends inline code
+func (t *TextBuilder) Code(code string) *TextBuilder {
+	switch t.mode {
+	case ParseModeHTML:
+		t.write("<code>" + EscapeHTML(code) + "</code>")
+	case ParseModeMarkdown:
+		t.write("`" + codeEscaper.Replace(code) + "`")
+	default:
+		t.write(code)
+	}
+	return t
+}
+
+// Pre appends code block on its own lines; language may be empty
+func (t *TextBuilder) Pre(code string, language string) *TextBuilder {
+	t.startBlock()
+	switch t.mode {
+	case ParseModeHTML:
+		if language != "" {
+			t.write(`<pre><code class="` + EscapeHTML(language) + `">` + EscapeHTML(code) + "</code></pre>")
+		} else {
+			t.write("<pre>" + EscapeHTML(code) + "</pre>")
+		}
+	case ParseModeMarkdown:
+		t.write("```" + codeEscaper.Replace(language) + "\n" + codeEscaper.Replace(code) + "\n```")
+	default:
+		t.write(code)
+	}
+	return t.endBlock()
+}
+
+func (t *TextBuilder) Link(text string, url string) *TextBuilder {
+	switch t.mode {
+	case ParseModeHTML:
+		t.write(`<a href="` + EscapeHTML(url) + `">` + EscapeHTML(text) + "</a>")
+	case ParseModeMarkdown:
+		t.write("[" + EscapeMarkdown(text) + "](" + urlEscaper.Replace(url) + ")")
+	default:
+		t.write(text + " (" + url + ")")
+	}
+	return t
+}
+
+// Mention appends mention of user by his ID
+func (t *TextBuilder) Mention(userID string) *TextBuilder {
+	t.write("@[" + userID + "]")
+	return t
+}
+
+// Quote appends quote on its own lines
+func (t *TextBuilder) Quote(text string) *TextBuilder {
+	t.startBlock()
+	switch t.mode {
+	case ParseModeHTML:
+		t.write("<blockquote>" + EscapeHTML(text) + "</blockquote>")
+	case ParseModeMarkdown:
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = ">" + EscapeMarkdown(line)
+		}
+		t.write(strings.Join(lines, "\n"))
+	default:
+		t.write(text)
+	}
+	return t.endBlock()
+}
+
+// OrderedList appends numbered list on its own lines
+func (t *TextBuilder) OrderedList(items ...string) *TextBuilder {
+	return t.list(items, "ol", func(i int) string { return strconv.Itoa(i+1) + ". " })
+}
+
+// UnorderedList appends bulleted list on its own lines
+func (t *TextBuilder) UnorderedList(items ...string) *TextBuilder {
+	return t.list(items, "ul", func(int) string { return "- " })
+}
+
+func (t *TextBuilder) list(items []string, tag string, marker func(i int) string) *TextBuilder {
+	t.startBlock()
+	if t.mode == ParseModeHTML {
+		t.write("<" + tag + ">")
+		for _, item := range items {
+			t.write("<li>" + EscapeHTML(item) + "</li>")
+		}
+		t.write("</" + tag + ">")
+		return t.endBlock()
+	}
+	for i, item := range items {
+		if i > 0 {
+			t.write("\n")
+		}
+		t.write(marker(i) + Escape(t.mode, item))
+	}
+	return t.endBlock()
+}
+
+// Line appends line break
+func (t *TextBuilder) Line() *TextBuilder {
+	t.write("\n")
+	return t
+}
+
+// String returns composed text
+func (t *TextBuilder) String() string { return t.b.String() }
+
+// Apply sets Text and ParseMode of message
+func (t *TextBuilder) Apply(msg *Message) {
+	msg.Text = t.String()
+	msg.ParseMode = t.mode
+}
+
+func (t *TextBuilder) wrap(text string, htmlOpen string, htmlClose string, markdown string) *TextBuilder {
+	switch t.mode {
+	case ParseModeHTML:
+		t.write(htmlOpen + EscapeHTML(text) + htmlClose)
+	case ParseModeMarkdown:
+		t.write(markdown + EscapeMarkdown(text) + markdown)
+	default:
+		t.write(text)
+	}
+	return t
+}
+
+func (t *TextBuilder) write(s string) {
+	if t.lineBreak {
+		t.b.WriteString("\n")
+		t.lineBreak = false
+	}
+	t.b.WriteString(s)
+}
+
+// startBlock moves block elements to a new line
+func (t *TextBuilder) startBlock() {
+	if t.b.Len() > 0 && !t.lineBreak && !strings.HasSuffix(t.b.String(), "\n") {
+		t.write("\n")
+	}
+}
+
+// endBlock makes the next text start on a new line
+func (t *TextBuilder) endBlock() *TextBuilder {
+	t.lineBreak = true
+	return t
+}
@@ -1,6 +1,10 @@
 package message
 
-import "io"
+import (
+	"io"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/format"
+)
 
 type Message struct {
 	ChatID         string
@@ -18,8 +22,15 @@ type FileMessage struct {
 	FileID   string
 	Filename string
 	Contents io.Reader
+	// Optional callback, invoked as Contents are uploaded
+	Progress ProgressFunc
 }
 
+// ProgressFunc reports number of file bytes sent so far.
+// Total is -1 when size of Contents can not be determined.
+// It is called from the goroutine which writes request body
+type ProgressFunc func(sent int64, total int64)
+
 type EditMessage struct {
 	Message
 	MessageToEditID string
@@ -55,7 +66,9 @@ const (
 	ButtonAttention ButtonStyle = "attention"
 )
 
-type MessageFormat struct{}
+// MessageFormat styles text by ranges (see format.Builder).
+// It can not be combined with ParseMode
+type MessageFormat = format.Format
 
 type ParseMode int
 
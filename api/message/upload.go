@@ -0,0 +1,140 @@
+package message
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+)
+
+// upload streams multipart/form-data body with a single file field
+// through a pipe, so file contents are never buffered in memory
+type upload struct {
+	reader      *io.PipeReader
+	contentType string
+	// Length of the whole body; -1 if unknown
+	length int64
+	done   chan struct{}
+	err    error
+}
+
+func newUpload(ctx context.Context, field string, filename string, contents io.Reader, progress ProgressFunc) *upload {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	u := &upload{
+		reader:      pr,
+		contentType: mw.FormDataContentType(),
+		length:      -1,
+		done:        make(chan struct{}),
+	}
+	size := contentSize(contents)
+	if size >= 0 {
+		u.length = multipartLength(mw.Boundary(), field, filename) + size
+	}
+	var reader io.Reader = &contextReader{ctx, contents}
+	if progress != nil {
+		reader = &progressReader{r: reader, total: size, progress: progress}
+	}
+	go func() {
+		defer close(u.done)
+		u.err = writeMultipart(mw, field, filename, reader)
+		pw.CloseWithError(u.err)
+	}()
+	return u
+}
+
+func writeMultipart(mw *multipart.Writer, field string, filename string, contents io.Reader) error {
+	fileWriter, err := mw.CreateFormFile(field, filename)
+	if err != nil {
+		return fmt.Errorf("unable to create file writer: %w", err)
+	}
+	if _, err = io.Copy(fileWriter, contents); err != nil {
+		return fmt.Errorf("unable to copy file contents: %w", err)
+	}
+	if err = mw.Close(); err != nil {
+		return fmt.Errorf("unable to finish multipart body: %w", err)
+	}
+	return nil
+}
+
+// Close stops the writing goroutine and waits for it.
+// Returns error occurred while writing the body, if any
+func (u *upload) Close() error {
+	u.reader.Close()
+	<-u.done
+	if u.err == io.ErrClosedPipe {
+		return nil
+	}
+	return u.err
+}
+
+// multipartLength returns size of multipart body without file contents
+func multipartLength(boundary string, field string, filename string) int64 {
+	counter := &countingWriter{}
+	mw := multipart.NewWriter(counter)
+	mw.SetBoundary(boundary)
+	mw.CreateFormFile(field, filename)
+	mw.Close()
+	return counter.n
+}
+
+// contentSize returns number of bytes left in reader or -1 if it can not be determined
+func contentSize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case *os.File:
+		stat, err := v.Stat()
+		if err != nil || !stat.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return stat.Size() - offset
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case interface{ Size() int64 }:
+		return v.Size()
+	}
+	return -1
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// contextReader stops reading once context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// progressReader reports number of bytes read after each Read
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.sent += int64(n)
+		r.progress(r.sent, r.total)
+	}
+	return n, err
+}
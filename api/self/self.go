@@ -0,0 +1,35 @@
+package self
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/apierr"
+)
+
+type SelfService struct {
+	client Client
+}
+
+func New(cli Client) *SelfService { return &SelfService{cli} }
+
+// /self/get
+func (s *SelfService) Get(ctx context.Context) (*BotInfo, error) {
+	req, err := s.client.PerformRequest(ctx, http.MethodGet, "/self/get", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get bot info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	info := &BotInfo{}
+	if err = apierr.Decode(resp, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,54 @@
+package self_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/s1em0nk3y/vkteams-bot"
+	"github.com/s1em0nk3y/vkteams-bot/api/self"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfService_Get(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		want      *self.BotInfo
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:     "Correct usage",
+			response: `{"ok":true,"userId":"1000","nick":"testbot","firstName":"Test","about":"About","photo":[{"url":"https://example.com/photo"}]}`,
+			want: &self.BotInfo{
+				UserID:    "1000",
+				Nick:      "testbot",
+				FirstName: "Test",
+				About:     "About",
+				Photo:     []self.Photo{{URL: "https://example.com/photo"}},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:     "Invalid token",
+			response: `{"ok":false,"description":"Invalid token"}`,
+			assertion: func(tt assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(tt, err, self.ErrNotOk)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/self/get", r.URL.Path)
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+			s := self.New(vkteams.New("token", vkteams.WithApiURL(server.URL)))
+			got, err := s.Get(context.Background())
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package self
+
+type BotInfo struct {
+	UserID    string  `json:"userId"`
+	Nick      string  `json:"nick"`
+	FirstName string  `json:"firstName"`
+	About     string  `json:"about"`
+	Photo     []Photo `json:"photo"`
+}
+
+type Photo struct {
+	URL string `json:"url"`
+}
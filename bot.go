@@ -8,21 +8,41 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/s1em0nk3y/vkteams-bot/api/apierr"
+	"github.com/s1em0nk3y/vkteams-bot/api/chat"
 	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/api/file"
 	"github.com/s1em0nk3y/vkteams-bot/api/message"
+	"github.com/s1em0nk3y/vkteams-bot/api/self"
+	"github.com/s1em0nk3y/vkteams-bot/ratelimit"
+	"github.com/s1em0nk3y/vkteams-bot/retry"
 )
 
 const defaultUrl = "https://myteam.mail.ru/bot/v1"
 
 type Bot struct {
-	client      *http.Client
-	apiUrl      string
+	client *http.Client
+	apiUrl string
+	// Parsed apiUrl; nil if it is invalid
+	apiBase     *url.URL
 	token       string
 	pollSeconds uint
+	retryPolicy retry.Policy
+	limiter     *ratelimit.Limiter
+	eventOpts   []event.Option
 	*message.MessageService
 	*event.EventService
+	*chat.ChatService
+	*file.FileService
+
+	selfService *self.SelfService
+	selfMu      sync.Mutex
+	selfInfo    *self.BotInfo
 }
 
 func New(token string, opts ...Option) *Bot {
@@ -31,15 +51,39 @@ func New(token string, opts ...Option) *Bot {
 		apiUrl:      defaultUrl,
 		token:       token,
 		pollSeconds: 60,
+		retryPolicy: retry.DefaultPolicy(),
 	}
 	for _, opt := range opts {
 		opt(b)
 	}
-	b.EventService = event.New(b, b.pollSeconds)
+	b.apiBase, _ = url.Parse(b.apiUrl)
+	b.client = withToken(b.client, b.apiBase, b.token)
+	b.EventService = event.New(b, b.pollSeconds,
+		append([]event.Option{event.WithRetryPolicy(b.retryPolicy)}, b.eventOpts...)...,
+	)
 	b.MessageService = message.New(b)
+	b.ChatService = chat.New(b)
+	b.FileService = file.New(b)
+	b.selfService = self.New(b)
 	return b
 }
 
+// Self returns information about the bot (/self/get).
+// The first successful answer is cached, so it is cheap to call on every event
+func (b *Bot) Self(ctx context.Context) (*self.BotInfo, error) {
+	b.selfMu.Lock()
+	defer b.selfMu.Unlock()
+	if b.selfInfo != nil {
+		return b.selfInfo, nil
+	}
+	info, err := b.selfService.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.selfInfo = info
+	return info, nil
+}
+
 func (b *Bot) PerformRequest(ctx context.Context, method string, path string, params url.Values, body io.Reader) (*http.Request, error) {
 	log := *zerolog.Ctx(ctx)
 	log = log.With().Str("path", b.apiUrl+path).Logger()
@@ -50,20 +94,96 @@ func (b *Bot) PerformRequest(ctx context.Context, method string, path string, pa
 	if params == nil {
 		params = url.Values{}
 	}
-	params.Set("token", b.token)
 	urlPath.RawQuery = params.Encode()
 	req, err := http.NewRequestWithContext(ctx, method, urlPath.String(), body)
 	log.Err(err).Msg("create request")
 	return req, err
 }
 
+// Do sends request, retrying network errors, 5xx and 429 responses according to retry policy.
+// Requests with body which can not be replayed (see http.Request.GetBody) are sent once.
+// Token is added to requests to API by transport of the bot and never appears in returned errors
 func (b *Bot) Do(req *http.Request) (*http.Response, error) {
+	resp, err := b.do(req)
+	return resp, redact(err, b.token)
+}
+
+func (b *Bot) do(req *http.Request) (*http.Response, error) {
 	if req == nil {
 		return nil, errors.New("no request provided")
 	}
-	resp, err := b.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error occured when sending request: %w", err)
+	log := zerolog.Ctx(req.Context()).With().Str("path", req.URL.Path).Logger()
+	for attempt := 1; ; attempt++ {
+		if err := b.wait(req); err != nil {
+			return nil, fmt.Errorf("error occured when waiting for rate limiter: %w", err)
+		}
+		resp, err := b.client.Do(req)
+		delay, ok := b.retryDelay(req, resp, err, attempt)
+		if !ok {
+			if err != nil {
+				return nil, fmt.Errorf("error occured when sending request: %w", err)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			log.Warn().Int("status", resp.StatusCode).Int("attempt", attempt).Dur("delay", delay).Msg("retrying request")
+		} else {
+			log.Warn().Err(redact(err, b.token)).Int("attempt", attempt).Dur("delay", delay).Msg("retrying request")
+		}
+		if err = retry.Sleep(req.Context(), delay); err != nil {
+			return nil, fmt.Errorf("error occured when sending request: %w", err)
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, fmt.Errorf("unable to rewind request body: %w", err)
+			}
+		}
+	}
+}
+
+// wait blocks until rate limiter allows to send request to API
+func (b *Bot) wait(req *http.Request) error {
+	if b.limiter == nil || !isAPIRequest(b.apiBase, req.URL) {
+		return nil
+	}
+	return b.limiter.Wait(req.Context(), req.URL.Query().Get("chatId"))
+}
+
+// retryDelay reports whether request should be sent again and how long to wait before it.
+// 429 responses are always retried: such requests were not handled by API.
+// Network errors and 5xx are retried only for read-only methods, since others could be already done
+func (b *Bot) retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= b.retryPolicy.MaxAttempts || !retry.Retryable(resp, err) {
+		return 0, false
+	}
+	if (resp == nil || resp.StatusCode != http.StatusTooManyRequests) && !b.readOnly(req) {
+		return 0, false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 0, false
+	}
+	if resp != nil {
+		if requested := apierr.ParseRetryAfter(resp.Header.Get("Retry-After")); requested > 0 {
+			return b.retryPolicy.RetryAfter(requested)
+		}
+	}
+	return b.retryPolicy.Delay(attempt), true
+}
+
+// readOnly reports whether request does not change anything, so it is safe to send it twice.
+// Requests outside of API (e.g. file downloads) are read-only if their method is GET or HEAD
+func (b *Bot) readOnly(req *http.Request) bool {
+	if !isAPIRequest(b.apiBase, req.URL) {
+		return req.Method == http.MethodGet || req.Method == http.MethodHead
+	}
+	path := strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(b.apiBase.Path, "/"))
+	switch {
+	case path == "/events/get", path == "/self/get", path == "/files/getInfo":
+		return true
+	case strings.HasPrefix(path, "/chats/get"):
+		return true
 	}
-	return resp, err
+	return false
 }
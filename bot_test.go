@@ -1,18 +1,24 @@
 package vkteams
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
+	"github.com/s1em0nk3y/vkteams-bot/retry"
+	"github.com/s1em0nk3y/vkteams-bot/vkteamstest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,7 +40,10 @@ func TestMain(m *testing.M) {
 	godotenv.Load()
 	testLogger = zerolog.New(zerolog.NewConsoleWriter())
 	if err := env.Parse(&TestCfg); err != nil {
-		testLogger.Fatal().Err(err).Send()
+		testLogger.Warn().Err(err).Msg("running against fake API")
+		server := vkteamstest.NewServer()
+		defer server.Close()
+		TestCfg.Token, TestCfg.URL = server.Token, server.URL
 	}
 	if !TestCfg.Proxy {
 		httpClient.Transport.(*http.Transport).Proxy = nil
@@ -55,29 +64,44 @@ func TestNew(t *testing.T) {
 		opts  []Option
 	}
 	tests := []struct {
-		name string
-		args args
-		want *Bot
+		name            string
+		args            args
+		wantUrl         string
+		wantPollSeconds uint
+		wantTransport   http.RoundTripper
 	}{
 		{
-			name: "All default",
-			args: args{},
-			want: &Bot{client: http.DefaultClient, apiUrl: defaultUrl},
+			name:            "All default",
+			args:            args{token: "token"},
+			wantUrl:         defaultUrl,
+			wantPollSeconds: 60,
+			wantTransport:   http.DefaultTransport,
 		},
 		{
-			name: "Custom url",
-			args: args{opts: []Option{WithApiURL("https://example.api/v1")}},
-			want: &Bot{apiUrl: "https://example.api/v1", client: http.DefaultClient},
+			name:            "Custom url",
+			args:            args{token: "token", opts: []Option{WithApiURL("https://example.api/v1"), WithPollSeconds(5)}},
+			wantUrl:         "https://example.api/v1",
+			wantPollSeconds: 5,
+			wantTransport:   http.DefaultTransport,
 		},
 		{
-			name: "Custom http client",
-			args: args{opts: []Option{WithHTTPClient(httpClient)}},
-			want: &Bot{apiUrl: defaultUrl, client: httpClient},
+			name:            "Custom http client",
+			args:            args{token: "token", opts: []Option{WithHTTPClient(httpClient)}},
+			wantUrl:         defaultUrl,
+			wantPollSeconds: 60,
+			wantTransport:   httpClient.Transport,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.want, New(tt.args.token, tt.args.opts...))
+			b := New(tt.args.token, tt.args.opts...)
+			assert.Equal(t, tt.wantUrl, b.apiUrl)
+			assert.Equal(t, tt.args.token, b.token)
+			assert.Equal(t, tt.wantPollSeconds, b.pollSeconds)
+			transport, ok := b.client.Transport.(*tokenTransport)
+			if assert.True(t, ok, "client must add token") {
+				assert.Same(t, tt.wantTransport, transport.base)
+			}
 		})
 	}
 }
@@ -244,10 +268,8 @@ func TestBot_PerformRequest(t *testing.T) {
 				path:   "/self/get",
 			},
 			want: func() *http.Request {
+				// Token is added by transport, so it is not a part of request URL
 				req, _ := http.NewRequest("GET", TestCfg.URL+"/self/get", nil)
-				req.URL.RawQuery = url.Values{
-					"token": {TestCfg.Token},
-				}.Encode()
 				return req
 			}(),
 			assertion: func(tt assert.TestingT, err error, i ...interface{}) bool {
@@ -288,7 +310,7 @@ func TestBot_Do(t *testing.T) {
 			},
 		},
 		{
-			name: "No token",
+			name: "Request to API built without PerformRequest",
 			args: func() *http.Request {
 				req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, testBot.apiUrl+"/self/get", nil)
 				return req
@@ -296,6 +318,7 @@ func TestBot_Do(t *testing.T) {
 			want: &http.Response{
 				StatusCode: http.StatusOK,
 			},
+			wantOk: true,
 			assertion: func(tt assert.TestingT, err error, i ...interface{}) bool {
 				return assert.Nil(tt, err)
 			},
@@ -344,3 +367,239 @@ func TestBot_Do(t *testing.T) {
 		})
 	}
 }
+
+func TestBot_Self(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"ok":true,"userId":"1000","nick":"testbot"}`))
+	}))
+	defer server.Close()
+	b := New("token", WithApiURL(server.URL))
+	for range 3 {
+		info, err := b.Self(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "testbot", info.Nick)
+	}
+	assert.Equal(t, 1, calls, "bot info must be cached")
+}
+
+func TestBot_DoRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		header     http.Header
+		policy     retry.Policy
+		wantStatus int
+		wantCalls  int
+	}{
+		{
+			name:       "Retry server errors",
+			statuses:   []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			policy:     retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			wantStatus: http.StatusOK,
+			wantCalls:  3,
+		},
+		{
+			name:       "Max attempts reached",
+			statuses:   []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+			policy:     retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  2,
+		},
+		{
+			name:       "Client errors are not retried",
+			statuses:   []int{http.StatusNotFound, http.StatusOK},
+			policy:     retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			wantStatus: http.StatusNotFound,
+			wantCalls:  1,
+		},
+		{
+			name:       "Retry-After longer than max delay",
+			statuses:   []int{http.StatusTooManyRequests, http.StatusOK},
+			header:     http.Header{"Retry-After": {"120"}},
+			policy:     retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second},
+			wantStatus: http.StatusTooManyRequests,
+			wantCalls:  1,
+		},
+		{
+			name:       "No retry",
+			statuses:   []int{http.StatusBadGateway, http.StatusOK},
+			policy:     retry.NoRetry(),
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header()[k] = v
+				}
+				w.WriteHeader(tt.statuses[calls])
+				calls++
+			}))
+			defer server.Close()
+			b := New("token", WithApiURL(server.URL), WithRetryPolicy(tt.policy))
+			req, err := b.PerformRequest(context.Background(), http.MethodGet, "/self/get", nil, nil)
+			assert.NoError(t, err)
+			resp, err := b.Do(req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
+
+func TestBot_DoRetryActions(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		status    int
+		wantCalls int
+	}{
+		{name: "Action is not resent after 502", path: "/messages/sendText", status: http.StatusBadGateway, wantCalls: 1},
+		{name: "Action is not resent after 504", path: "/chats/blockUser", status: http.StatusGatewayTimeout, wantCalls: 1},
+		{name: "Action is resent after 429", path: "/messages/sendText", status: http.StatusTooManyRequests, wantCalls: 2},
+		{name: "Read-only method is resent after 502", path: "/chats/getInfo", status: http.StatusBadGateway, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					w.WriteHeader(tt.status)
+				}
+			}))
+			defer server.Close()
+			b := New("token", WithApiURL(server.URL+"/bot/v1"),
+				WithRetryPolicy(retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+			req, err := b.PerformRequest(context.Background(), http.MethodGet, tt.path, nil, nil)
+			assert.NoError(t, err)
+			resp, err := b.Do(req)
+			assert.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+
+	t.Run("Action is not resent after network error", func(t *testing.T) {
+		transport := &recordingTransport{}
+		b := New("token", WithHTTPClient(&http.Client{Transport: failingRecorder{transport}}),
+			WithRetryPolicy(retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+		req, err := b.PerformRequest(context.Background(), http.MethodGet, "/messages/sendText", nil, nil)
+		assert.NoError(t, err)
+		_, err = b.Do(req)
+		assert.ErrorIs(t, err, errTransport)
+		assert.Len(t, transport.urls, 1)
+	})
+}
+
+// failingRecorder records request and fails it
+type failingRecorder struct {
+	*recordingTransport
+}
+
+func (f failingRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.recordingTransport.RoundTrip(req)
+	return failingTransport{}.RoundTrip(req)
+}
+
+// failingTransport fails every request with error containing its URL, like some proxies do
+type failingTransport struct{}
+
+var errTransport = errors.New("transport failed")
+
+func (failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("%w: %s", errTransport, req.URL)
+}
+
+func TestBot_Token(t *testing.T) {
+	const token = "secret-token"
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.URL.Query().Get("token"))
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	t.Run("Added by transport", func(t *testing.T) {
+		tokens = nil
+		b := New(token, WithApiURL(server.URL+"/bot/v1"))
+		req, err := b.PerformRequest(context.Background(), http.MethodGet, "/self/get", nil, nil)
+		assert.NoError(t, err)
+		assert.NotContains(t, req.URL.String(), token)
+		resp, err := b.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Same(t, req, resp.Request)
+
+		// Files are downloaded from other URLs which must not receive token
+		req, _ = http.NewRequest(http.MethodGet, server.URL+"/files/download/1", nil)
+		resp, err = b.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, []string{token, ""}, tokens)
+	})
+
+	t.Run("Redacted in errors and logs", func(t *testing.T) {
+		var logs bytes.Buffer
+		ctx := zerolog.New(&logs).WithContext(context.Background())
+		b := New(token, WithApiURL(server.URL),
+			WithHTTPClient(&http.Client{Transport: failingTransport{}}),
+			WithRetryPolicy(retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+		)
+		req, err := b.PerformRequest(ctx, http.MethodGet, "/self/get", nil, nil)
+		assert.NoError(t, err)
+		_, err = b.Do(req)
+		assert.ErrorIs(t, err, errTransport)
+		assert.NotContains(t, err.Error(), token)
+		assert.Contains(t, err.Error(), redactedToken)
+		assert.Contains(t, logs.String(), "retrying request")
+		assert.NotContains(t, logs.String(), token)
+	})
+}
+
+// recordingTransport answers every request with empty OK response and keeps URLs of requests
+type recordingTransport struct {
+	urls []string
+}
+
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.urls = append(r.urls, req.URL.String())
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestBot_TokenScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		wantToken bool
+	}{
+		{name: "API method", url: "https://api.example.com/bot/v1/self/get", wantToken: true},
+		{name: "API base", url: "https://api.example.com/bot/v1", wantToken: true},
+		{name: "Lookalike host", url: "https://api.example.com.attacker.net/bot/v1/self/get"},
+		{name: "Userinfo", url: "https://api.example.com@attacker.net/bot/v1/self/get"},
+		{name: "Other port", url: "https://api.example.com:8443/bot/v1/self/get"},
+		{name: "Other scheme", url: "http://api.example.com/bot/v1/self/get"},
+		{name: "Path prefix without separator", url: "https://api.example.com/bot/v10/self/get"},
+		{name: "Outside base path", url: "https://api.example.com/files/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &recordingTransport{}
+			b := New("secret-token", WithApiURL("https://api.example.com/bot/v1"),
+				WithHTTPClient(&http.Client{Transport: transport}))
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			assert.NoError(t, err)
+			_, err = b.Do(req)
+			assert.NoError(t, err)
+			if assert.Len(t, transport.urls, 1) {
+				sent, _ := url.Parse(transport.urls[0])
+				assert.Equal(t, tt.wantToken, sent.Query().Get("token") == "secret-token")
+			}
+		})
+	}
+}
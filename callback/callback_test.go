@@ -0,0 +1,164 @@
+package callback
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type approve struct {
+	ID      int
+	Env     string
+	Force   bool
+	Attempt uint8
+	comment string
+	Skipped string `callback:"-"`
+}
+
+func TestCodec(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		action string
+		value  any
+		want   string
+	}{
+		{
+			name:   "Plain",
+			action: "approve",
+			value:  approve{ID: 1234, Env: "prod", Force: true, Attempt: 2, comment: "x", Skipped: "x"},
+			want:   "approve:ya:prod:1:2",
+		},
+		{
+			name:   "Escaped",
+			action: "a:b",
+			value:  &approve{ID: -1, Env: "50%:off"},
+			want:   "a%3Ab:-1:50%25%3Aoff:0:0",
+		},
+		{
+			name:   "Without value",
+			action: "cancel",
+			want:   "cancel",
+		},
+		{
+			name:   "Signed",
+			opts:   []Option{WithKey([]byte("secret"))},
+			action: "cancel",
+		},
+		{
+			name:   "Signed with expiration",
+			opts:   []Option{WithKey([]byte("secret")), WithTTL(time.Hour)},
+			action: "approve",
+			value:  approve{ID: 1, Env: "dev"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.opts...)
+			data, err := c.Encode(tt.action, tt.value)
+			require.NoError(t, err)
+			if tt.want != "" {
+				assert.Equal(t, tt.want, data)
+			}
+
+			var got approve
+			var target any
+			if tt.value != nil {
+				target = &got
+			}
+			action, err := c.Decode(data, target)
+			require.NoError(t, err)
+			assert.Equal(t, tt.action, action)
+			if v, ok := tt.value.(approve); ok {
+				v.comment, v.Skipped = "", ""
+				assert.Equal(t, v, got)
+			}
+		})
+	}
+}
+
+func TestCodec_Errors(t *testing.T) {
+	now := time.Now()
+	c := New(WithKey([]byte("secret")), WithTTL(time.Minute))
+	c.now = func() time.Time { return now }
+	data, err := c.Encode("approve", approve{ID: 1})
+	require.NoError(t, err)
+
+	_, err = c.Decode(strings.Replace(data, "approve", "reject", 1), nil)
+	assert.ErrorIs(t, err, ErrBadSignature)
+	_, err = New(WithKey([]byte("other"))).Decode(data, nil)
+	assert.ErrorIs(t, err, ErrBadSignature)
+	_, err = c.Decode("approve:1", nil)
+	assert.ErrorIs(t, err, ErrInvalidData, "data without signature is not produced by codec")
+	_, err = c.Decode("approve:1:prod", nil)
+	assert.ErrorIs(t, err, ErrInvalidData)
+
+	c.now = func() time.Time { return now.Add(time.Minute) }
+	_, err = c.Decode(data, nil)
+	assert.ErrorIs(t, err, ErrExpired)
+
+	plain := New()
+	_, err = plain.Decode("approve:1", &approve{})
+	assert.ErrorIs(t, err, ErrInvalidData)
+	_, err = plain.Decode("approve:zzzzzzzzzzzzzzzzz:prod:1:0", &approve{})
+	assert.ErrorIs(t, err, ErrInvalidData)
+	_, err = plain.Decode("", nil)
+	assert.ErrorIs(t, err, ErrInvalidData)
+	_, err = plain.Encode("approve", struct{ Tags []string }{})
+	assert.ErrorContains(t, err, "unsupported field kind slice")
+	_, err = plain.Encode("approve", approve{Env: strings.Repeat("x", 64)})
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestMux(t *testing.T) {
+	codec := New(WithKey([]byte("secret")))
+	mux := NewMux(codec)
+	var approved []approve
+	On(mux, "approve", func(ctx context.Context, ev event.Event, v approve) error {
+		approved = append(approved, v)
+		return nil
+	})
+	callback := func(data string) event.Event {
+		ev := event.Event{Type: event.EventCallbackQuery}
+		ev.CallbackData = data
+		return ev
+	}
+	ctx := context.Background()
+
+	data, err := codec.Encode("approve", approve{ID: 7, Env: "prod"})
+	require.NoError(t, err)
+	assert.NoError(t, mux.Handle(ctx, callback(data)))
+	assert.Equal(t, []approve{{ID: 7, Env: "prod"}}, approved)
+
+	assert.NoError(t, mux.Handle(ctx, event.Event{Type: event.EventNewMessage}), "other events are ignored")
+
+	unknown, err := codec.Encode("reject", nil)
+	require.NoError(t, err)
+	forged := strings.Replace(data, "prod", "test", 1)
+	assert.ErrorIs(t, mux.Handle(ctx, callback(unknown)), ErrUnknownAction)
+	assert.ErrorIs(t, mux.Handle(ctx, callback("legacy")), ErrInvalidData)
+	assert.ErrorIs(t, mux.Handle(ctx, callback(forged)), ErrBadSignature)
+
+	var invalid []error
+	mux.OnInvalid(func(ctx context.Context, ev event.Event, err error) error {
+		invalid = append(invalid, err)
+		return nil
+	})
+	var fallback []string
+	mux.Fallback(func(ctx context.Context, ev event.Event) error {
+		fallback = append(fallback, ev.CallbackData)
+		return nil
+	})
+	assert.NoError(t, mux.Handle(ctx, callback(unknown)))
+	assert.NoError(t, mux.Handle(ctx, callback("legacy")))
+	assert.NoError(t, mux.Handle(ctx, callback("legacy:a:b")))
+	assert.NoError(t, mux.Handle(ctx, callback(forged)))
+	assert.Equal(t, []string{unknown, "legacy", "legacy:a:b"}, fallback, "plain data must go to fallback")
+	require.Len(t, invalid, 1)
+	assert.ErrorIs(t, invalid[0], ErrBadSignature)
+}
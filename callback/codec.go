@@ -0,0 +1,252 @@
+// Package callback encodes typed structs into compact, optionally signed callback data
+// of inline buttons and dispatches callbackQuery events by action name
+package callback
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/message"
+)
+
+var (
+	ErrInvalidData  = errors.New("invalid callback data")
+	ErrBadSignature = errors.New("bad callback data signature")
+	ErrExpired      = errors.New("callback data expired")
+	ErrTooLong      = errors.New("callback data is too long")
+)
+
+const (
+	separator = ":"
+	// Length of HMAC in bytes, it takes 11 characters after encoding
+	signatureLength = 8
+)
+
+var escaper = strings.NewReplacer("%", "%25", separator, "%3A")
+
+// Codec encodes action name and exported fields of a struct in order of declaration:
+//
+//	approve:4d2:prod
+//
+// Supported field kinds are string, bool, integers and unsigned integers;
+// fields tagged `callback:"-"` are skipped. When signing key is set, expiration time
+// and HMAC-SHA256 signature are appended, so data can not be forged by clients
+type Codec struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+}
+
+type Option func(*Codec)
+
+// WithKey signs data with HMAC using given key
+func WithKey(key []byte) Option {
+	return func(c *Codec) {
+		c.key = key
+	}
+}
+
+// WithTTL makes signed data expire after given duration. It requires WithKey
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Codec) {
+		c.ttl = ttl
+	}
+}
+
+func New(opts ...Option) *Codec {
+	c := &Codec{now: time.Now}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Encode returns callback data for action and fields of v.
+// V must be a struct, pointer to a struct or nil.
+// Result longer than message.MaxCallbackDataLength is an error matching ErrTooLong
+func (c *Codec) Encode(action string, v any) (string, error) {
+	if action == "" {
+		return "", fmt.Errorf("%w: empty action", ErrInvalidData)
+	}
+	fields, err := marshal(v)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(fields)+3)
+	parts = append(parts, escaper.Replace(action))
+	for _, f := range fields {
+		parts = append(parts, escaper.Replace(f))
+	}
+	if c.key != nil {
+		expires := ""
+		if c.ttl > 0 {
+			expires = strconv.FormatInt(c.now().Add(c.ttl).Unix(), 36)
+		}
+		parts = append(parts, expires)
+		parts = append(parts, c.sign(strings.Join(parts, separator)))
+	}
+	data := strings.Join(parts, separator)
+	if len(data) > message.MaxCallbackDataLength {
+		return "", fmt.Errorf("%w: %d bytes, limit is %d", ErrTooLong, len(data), message.MaxCallbackDataLength)
+	}
+	return data, nil
+}
+
+// Button returns callback button with encoded data
+func (c *Codec) Button(text string, action string, v any) (message.Button, error) {
+	data, err := c.Encode(action, v)
+	if err != nil {
+		return message.Button{}, err
+	}
+	return message.CallbackButton(text, data), nil
+}
+
+// Decode checks signature and expiration of data, fills v (pointer to a struct or nil)
+// and returns action name
+func (c *Codec) Decode(data string, v any) (action string, err error) {
+	action, fields, err := c.parse(data)
+	if err != nil {
+		return "", err
+	}
+	return action, unmarshal(fields, v)
+}
+
+func (c *Codec) parse(data string) (action string, fields []string, err error) {
+	parts := strings.Split(data, separator)
+	if c.key != nil {
+		n := len(parts) - 1
+		// Data without signature segment was not produced by codec, e.g. plain button data
+		if n < 2 || !isSignature(parts[n]) {
+			return "", nil, fmt.Errorf("%w: no signature", ErrInvalidData)
+		}
+		signed := data[:len(data)-len(parts[n])-len(separator)]
+		if !hmac.Equal([]byte(parts[n]), []byte(c.sign(signed))) {
+			return "", nil, ErrBadSignature
+		}
+		if expires := parts[n-1]; expires != "" {
+			unix, err := strconv.ParseInt(expires, 36, 64)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: expiration time: %w", ErrInvalidData, err)
+			}
+			if !c.now().Before(time.Unix(unix, 0)) {
+				return "", nil, ErrExpired
+			}
+		}
+		parts = parts[:n-1]
+	}
+	for i, p := range parts {
+		if parts[i], err = url.PathUnescape(p); err != nil {
+			return "", nil, fmt.Errorf("%w: %w", ErrInvalidData, err)
+		}
+	}
+	if parts[0] == "" {
+		return "", nil, fmt.Errorf("%w: empty action", ErrInvalidData)
+	}
+	return parts[0], parts[1:], nil
+}
+
+// isSignature reports whether s looks like encoded signature
+func isSignature(s string) bool {
+	if len(s) != base64.RawURLEncoding.EncodedLen(signatureLength) {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(s)
+	return err == nil
+}
+
+func (c *Codec) sign(data string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureLength])
+}
+
+// fieldsOf returns encoded fields of struct value
+func fieldsOf(v reflect.Value) []reflect.Value {
+	var fields []reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Tag.Get("callback") == "-" {
+			continue
+		}
+		fields = append(fields, v.Field(i))
+	}
+	return fields
+}
+
+func marshal(v any) ([]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("callback: unable to encode %T, struct expected", v)
+	}
+	var result []string
+	for _, f := range fieldsOf(rv) {
+		switch f.Kind() {
+		case reflect.String:
+			result = append(result, f.String())
+		case reflect.Bool:
+			b := "0"
+			if f.Bool() {
+				b = "1"
+			}
+			result = append(result, b)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			result = append(result, strconv.FormatInt(f.Int(), 36))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			result = append(result, strconv.FormatUint(f.Uint(), 36))
+		default:
+			return nil, fmt.Errorf("callback: unsupported field kind %s in %T", f.Kind(), v)
+		}
+	}
+	return result, nil
+}
+
+func unmarshal(values []string, v any) error {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("callback: unable to decode into %T, pointer to struct expected", v)
+	}
+	fields := fieldsOf(rv.Elem())
+	if len(values) != len(fields) {
+		return fmt.Errorf("%w: %d fields, %T has %d", ErrInvalidData, len(values), v, len(fields))
+	}
+	for i, f := range fields {
+		var err error
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(values[i])
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(values[i])
+			f.SetBool(b)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var n int64
+			n, err = strconv.ParseInt(values[i], 36, f.Type().Bits())
+			f.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			var n uint64
+			n, err = strconv.ParseUint(values[i], 36, f.Type().Bits())
+			f.SetUint(n)
+		default:
+			return fmt.Errorf("callback: unsupported field kind %s in %T", f.Kind(), v)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: field %d: %w", ErrInvalidData, i+1, err)
+		}
+	}
+	return nil
+}
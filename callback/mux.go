@@ -0,0 +1,98 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/router"
+)
+
+var ErrUnknownAction = errors.New("unknown callback action")
+
+// InvalidHandler is called for signed callback data with bad signature or expired one.
+// Useful to answer the callback with "button is outdated"
+type InvalidHandler func(ctx context.Context, ev event.Event, err error) error
+
+// Mux dispatches callbackQuery events to handlers by action name.
+// It can be registered as router handler:
+//
+//	mux := callback.NewMux(codec)
+//	callback.On(mux, "approve", func(ctx context.Context, ev event.Event, req Approve) error { ... })
+//	r.OnCallbackQuery(mux.Handle)
+type Mux struct {
+	codec     *Codec
+	mu        sync.RWMutex
+	handlers  map[string]func(ctx context.Context, ev event.Event, fields []string) error
+	fallback  router.Handler
+	onInvalid InvalidHandler
+}
+
+func NewMux(codec *Codec) *Mux {
+	return &Mux{
+		codec:    codec,
+		handlers: map[string]func(ctx context.Context, ev event.Event, fields []string) error{},
+	}
+}
+
+// On registers handler for action, replacing previous one. Callback data is decoded into T,
+// which must be a struct (see Codec)
+func On[T any](m *Mux, action string, h func(ctx context.Context, ev event.Event, v T) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[action] = func(ctx context.Context, ev event.Event, fields []string) error {
+		var v T
+		if err := unmarshal(fields, &v); err != nil {
+			return err
+		}
+		return h(ctx, ev, v)
+	}
+}
+
+// Fallback registers handler for callback data not produced by codec (e.g. plain data of
+// buttons sent before codec got a key) or having unknown action.
+// Without it such events are reported as errors
+func (m *Mux) Fallback(h router.Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fallback = h
+}
+
+// OnInvalid registers handler for signed data with bad signature or expired TTL.
+// Without it errors are returned
+func (m *Mux) OnInvalid(h InvalidHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onInvalid = h
+}
+
+// Handle decodes CallbackData of event and runs handler of its action.
+// Events of other types are ignored
+func (m *Mux) Handle(ctx context.Context, ev event.Event) error {
+	if ev.Type != event.EventCallbackQuery {
+		return nil
+	}
+	action, fields, err := m.codec.parse(ev.CallbackData)
+	m.mu.RLock()
+	h, ok := m.handlers[action]
+	fallback, onInvalid := m.fallback, m.onInvalid
+	m.mu.RUnlock()
+
+	switch {
+	case errors.Is(err, ErrBadSignature), errors.Is(err, ErrExpired):
+		if onInvalid != nil {
+			return onInvalid(ctx, ev, err)
+		}
+		return err
+	case err == nil && ok:
+		return h(ctx, ev, fields)
+	case fallback != nil:
+		return fallback(ctx, ev)
+	case err != nil:
+		return err
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownAction, action)
+	}
+}
@@ -0,0 +1,266 @@
+// Package command routes slash commands (/deploy service prod) to handlers
+// and generates /help from registered commands
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/api/message"
+	"github.com/s1em0nk3y/vkteams-bot/router"
+)
+
+const defaultUnknownReply = "Unknown command /%s. Send /help to see available commands"
+
+// Handler processes command with parsed arguments
+type Handler func(ctx context.Context, ev event.Event, args Args) error
+
+// Sender sends replies, e.g. *vkteams.Bot
+type Sender interface {
+	SendText(ctx context.Context, msg *message.Message) (string, error)
+}
+
+type Arg struct {
+	Name        string
+	Description string
+	// Optional arguments must follow required ones
+	Optional bool
+	// Variadic argument takes all the remaining words; it must be the last one
+	Variadic bool
+}
+
+type Command struct {
+	// Name without leading slash
+	Name        string
+	Aliases     []string
+	Description string
+	Args        []Arg
+	Handler     Handler
+}
+
+// Usage returns command signature, e.g. /deploy <service> [env]
+func (c *Command) Usage() string {
+	usage := "/" + c.Name
+	for _, arg := range c.Args {
+		name := arg.Name
+		if arg.Variadic {
+			name += "..."
+		}
+		if arg.Optional {
+			usage += " [" + name + "]"
+		} else {
+			usage += " <" + name + ">"
+		}
+	}
+	return usage
+}
+
+// Args are command arguments bound to names of Command.Args
+type Args struct {
+	values map[string][]string
+	raw    []string
+}
+
+// Get returns argument by name; variadic argument is joined with spaces
+func (a Args) Get(name string) string { return strings.Join(a.values[name], " ") }
+
+// List returns all words of argument (useful for variadic ones)
+func (a Args) List(name string) []string { return a.values[name] }
+
+// Raw returns all arguments as they were parsed
+func (a Args) Raw() []string { return a.raw }
+
+type Registry struct {
+	sender       Sender
+	botNick      string
+	unknownReply string
+	fallback     router.Handler
+	commands     []*Command
+	byName       map[string]*Command
+}
+
+type Option func(*Registry)
+
+// WithBotNick ignores commands addressed to other bots (/cmd@otherbot)
+func WithBotNick(nick string) Option {
+	return func(r *Registry) {
+		r.botNick = nick
+	}
+}
+
+// WithUnknownReply sets reply to unknown commands; %s is replaced with command name.
+// Empty reply makes unknown commands ignored
+func WithUnknownReply(reply string) Option {
+	return func(r *Registry) {
+		r.unknownReply = reply
+	}
+}
+
+// WithFallback sets handler for messages which are not commands
+func WithFallback(h router.Handler) Option {
+	return func(r *Registry) {
+		r.fallback = h
+	}
+}
+
+// New creates registry with built-in /help command
+func New(sender Sender, opts ...Option) *Registry {
+	r := &Registry{
+		sender:       sender,
+		unknownReply: defaultUnknownReply,
+		byName:       map[string]*Command{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.MustRegister(Command{
+		Name:        "help",
+		Description: "Show available commands",
+		Args:        []Arg{{Name: "command", Optional: true}},
+		Handler:     r.help,
+	})
+	return r
+}
+
+// Register adds command. Names and aliases are case insensitive and must be unique
+func (r *Registry) Register(cmd Command) error {
+	if cmd.Name == "" || cmd.Handler == nil {
+		return fmt.Errorf("command must have name and handler")
+	}
+	optional := false
+	for i, arg := range cmd.Args {
+		if arg.Variadic && i != len(cmd.Args)-1 {
+			return fmt.Errorf("command /%s: variadic argument %s must be the last one", cmd.Name, arg.Name)
+		}
+		if optional && !arg.Optional {
+			return fmt.Errorf("command /%s: required argument %s follows optional one", cmd.Name, arg.Name)
+		}
+		optional = arg.Optional
+	}
+	names := append([]string{cmd.Name}, cmd.Aliases...)
+	for _, name := range names {
+		if _, ok := r.byName[strings.ToLower(name)]; ok {
+			return fmt.Errorf("command /%s is already registered", name)
+		}
+	}
+	c := &cmd
+	for _, name := range names {
+		r.byName[strings.ToLower(name)] = c
+	}
+	r.commands = append(r.commands, c)
+	return nil
+}
+
+// MustRegister is like Register but panics on error
+func (r *Registry) MustRegister(cmd Command) {
+	if err := r.Register(cmd); err != nil {
+		panic(err)
+	}
+}
+
+// Commands returns registered commands in order of registration
+func (r *Registry) Commands() []*Command { return r.commands }
+
+// Handle runs command from message text. It can be registered as router handler:
+//
+//	rt.OnNewMessage(registry.Handle)
+func (r *Registry) Handle(ctx context.Context, ev event.Event) error {
+	inv, ok, err := Parse(ev.Text)
+	if !ok {
+		if r.fallback != nil {
+			return r.fallback(ctx, ev)
+		}
+		return nil
+	}
+	if inv.Mention != "" && r.botNick != "" && !strings.EqualFold(inv.Mention, r.botNick) {
+		return nil
+	}
+	if err != nil {
+		return r.reply(ctx, ev, fmt.Sprintf("Unable to parse command: %s", err))
+	}
+	cmd, ok := r.byName[inv.Name]
+	if !ok {
+		return r.replyUnknown(ctx, ev, inv.Name)
+	}
+	args, err := bind(cmd, inv.Args)
+	if err != nil {
+		return r.reply(ctx, ev, fmt.Sprintf("%s\nUsage: %s", err, cmd.Usage()))
+	}
+	return cmd.Handler(ctx, ev, args)
+}
+
+func bind(cmd *Command, raw []string) (Args, error) {
+	args := Args{values: map[string][]string{}, raw: raw}
+	for i, arg := range cmd.Args {
+		if i >= len(raw) {
+			if !arg.Optional {
+				return args, fmt.Errorf("missing argument %s", arg.Name)
+			}
+			break
+		}
+		if arg.Variadic {
+			args.values[arg.Name] = raw[i:]
+			return args, nil
+		}
+		args.values[arg.Name] = raw[i : i+1]
+	}
+	if len(raw) > len(cmd.Args) {
+		return args, fmt.Errorf("too many arguments")
+	}
+	return args, nil
+}
+
+// replyUnknown sends unknown command reply, if it is not disabled
+func (r *Registry) replyUnknown(ctx context.Context, ev event.Event, name string) error {
+	if r.unknownReply == "" {
+		return nil
+	}
+	return r.reply(ctx, ev, strings.ReplaceAll(r.unknownReply, "%s", name))
+}
+
+func (r *Registry) help(ctx context.Context, ev event.Event, args Args) error {
+	if name := strings.TrimPrefix(args.Get("command"), "/"); name != "" {
+		cmd, ok := r.byName[strings.ToLower(name)]
+		if !ok {
+			return r.replyUnknown(ctx, ev, name)
+		}
+		return r.reply(ctx, ev, commandHelp(cmd))
+	}
+	lines := make([]string, 0, len(r.commands)+1)
+	lines = append(lines, "Available commands:")
+	for _, cmd := range r.commands {
+		line := cmd.Usage()
+		if cmd.Description != "" {
+			line += " - " + cmd.Description
+		}
+		lines = append(lines, line)
+	}
+	return r.reply(ctx, ev, strings.Join(lines, "\n"))
+}
+
+func commandHelp(cmd *Command) string {
+	lines := []string{cmd.Usage()}
+	if cmd.Description != "" {
+		lines = append(lines, cmd.Description)
+	}
+	if len(cmd.Aliases) > 0 {
+		lines = append(lines, "Aliases: /"+strings.Join(cmd.Aliases, ", /"))
+	}
+	for _, arg := range cmd.Args {
+		if arg.Description != "" {
+			lines = append(lines, arg.Name+" - "+arg.Description)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *Registry) reply(ctx context.Context, ev event.Event, text string) error {
+	_, err := r.sender.SendText(ctx, &message.Message{
+		ChatID:     ev.Chat.ID,
+		Text:       text,
+		ReplyMsgID: ev.MessageID,
+	})
+	return err
+}
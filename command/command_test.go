@@ -0,0 +1,178 @@
+package command_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/api/message"
+	"github.com/s1em0nk3y/vkteams-bot/command"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentMessages []*message.Message
+
+func (s *sentMessages) SendText(ctx context.Context, msg *message.Message) (string, error) {
+	*s = append(*s, msg)
+	return "1", nil
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		want      *command.Invocation
+		wantOk    bool
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "Not a command",
+			text:      "hello /world",
+			assertion: assert.NoError,
+		},
+		{
+			name:      "Without arguments",
+			text:      "/OnCall",
+			want:      &command.Invocation{Name: "oncall"},
+			wantOk:    true,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "Mention and quoted args",
+			text:      `/deploy@deploybot  service "prod eu" "say \"hi\"" a\ b`,
+			want:      &command.Invocation{Name: "deploy", Mention: "deploybot", Args: []string{"service", "prod eu", `say "hi"`, "a b"}},
+			wantOk:    true,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "Apostrophe is literal",
+			text:      `/remind I'm late for 'standup`,
+			want:      &command.Invocation{Name: "remind", Args: []string{"I'm", "late", "for", "'standup"}},
+			wantOk:    true,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "Newline after name",
+			text:      "/note\nfirst line",
+			want:      &command.Invocation{Name: "note", Args: []string{"first", "line"}},
+			wantOk:    true,
+			assertion: assert.NoError,
+		},
+		{
+			name:   "Unterminated quote",
+			text:   `/deploy "service`,
+			want:   &command.Invocation{Name: "deploy"},
+			wantOk: true,
+			assertion: func(tt assert.TestingT, err error, i ...interface{}) bool {
+				return assert.ErrorIs(tt, err, command.ErrUnterminatedQuote)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := command.Parse(tt.text)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRegistry_Handle(t *testing.T) {
+	sent := &sentMessages{}
+	var deployed []string
+	r := command.New(sent, command.WithBotNick("deploybot"))
+	require.NoError(t, r.Register(command.Command{
+		Name:        "deploy",
+		Aliases:     []string{"d"},
+		Description: "Deploy service",
+		Args: []command.Arg{
+			{Name: "service"},
+			{Name: "env", Optional: true},
+		},
+		Handler: func(ctx context.Context, ev event.Event, args command.Args) error {
+			deployed = append(deployed, args.Get("service")+":"+args.Get("env"))
+			return nil
+		},
+	}))
+	assert.Error(t, r.Register(command.Command{
+		Name:    "D",
+		Handler: func(ctx context.Context, ev event.Event, args command.Args) error { return nil },
+	}), "alias must be unique")
+
+	handle := func(text string) {
+		ev := event.Event{Type: event.EventNewMessage}
+		ev.Chat.ID = "chat"
+		ev.MessageID = "msg"
+		ev.Text = text
+		assert.NoError(t, r.Handle(context.Background(), ev))
+	}
+	handle("/deploy api prod")
+	handle("/d@DeployBot web")
+	handle("/deploy@otherbot api prod")
+	handle("just text")
+	assert.Equal(t, []string{"api:prod", "web:"}, deployed)
+	assert.Empty(t, *sent)
+
+	handle("/deploy")
+	handle("/deploy a b c")
+	handle("/unknown")
+	handle("/help")
+	handle("/help deploy")
+	replies := []string{}
+	for _, msg := range *sent {
+		assert.Equal(t, "chat", msg.ChatID)
+		assert.Equal(t, "msg", msg.ReplyMsgID)
+		replies = append(replies, msg.Text)
+	}
+	assert.Equal(t, []string{
+		"missing argument service\nUsage: /deploy <service> [env]",
+		"too many arguments\nUsage: /deploy <service> [env]",
+		"Unknown command /unknown. Send /help to see available commands",
+		"Available commands:\n/help [command] - Show available commands\n/deploy <service> [env] - Deploy service",
+		"/deploy <service> [env]\nDeploy service\nAliases: /d",
+	}, replies)
+}
+
+func TestRegistry_HelpUnknown(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  []string
+	}{
+		{name: "Custom reply", reply: "No /%s here", want: []string{"No /nope here"}},
+		{name: "Disabled reply", reply: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := &sentMessages{}
+			r := command.New(sent, command.WithUnknownReply(tt.reply))
+			ev := event.Event{Type: event.EventNewMessage}
+			ev.Text = "/help nope"
+			assert.NoError(t, r.Handle(context.Background(), ev))
+			var replies []string
+			for _, msg := range *sent {
+				replies = append(replies, msg.Text)
+			}
+			assert.Equal(t, tt.want, replies)
+		})
+	}
+}
+
+func TestRegistry_Variadic(t *testing.T) {
+	var got []string
+	r := command.New(&sentMessages{}, command.WithUnknownReply(""))
+	r.MustRegister(command.Command{
+		Name: "echo",
+		Args: []command.Arg{{Name: "words", Variadic: true}},
+		Handler: func(ctx context.Context, ev event.Event, args command.Args) error {
+			got = args.List("words")
+			return nil
+		},
+	})
+	ev := event.Event{}
+	ev.Text = "/echo one two three"
+	assert.NoError(t, r.Handle(context.Background(), ev))
+	assert.Equal(t, []string{"one", "two", "three"}, got)
+}
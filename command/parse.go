@@ -0,0 +1,91 @@
+package command
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+var ErrUnterminatedQuote = errors.New("unterminated quote")
+
+// Invocation is a parsed command message: /name@mention arg1 "quoted arg"
+type Invocation struct {
+	// Lowercased command name without leading slash
+	Name string
+	// Bot nick after @, if present
+	Mention string
+	Args    []string
+}
+
+// Parse parses command message. It returns false if text is not a command.
+// On error Invocation has only Name and Mention.
+// Arguments are separated by spaces; double quotes group words, backslash escapes next character.
+// Apostrophes are ordinary characters, so "/remind I'm late" has args I'm and late
+func Parse(text string) (*Invocation, bool, error) {
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	if !strings.HasPrefix(text, "/") {
+		return nil, false, nil
+	}
+	head, rest, _ := strings.Cut(text[1:], " ")
+	if i := strings.IndexFunc(head, unicode.IsSpace); i >= 0 {
+		head, rest = head[:i], head[i:]+" "+rest
+	}
+	name, mention, _ := strings.Cut(head, "@")
+	if name == "" {
+		return nil, false, nil
+	}
+	inv := &Invocation{
+		Name:    strings.ToLower(name),
+		Mention: mention,
+	}
+	args, err := splitArgs(rest)
+	if err != nil {
+		return inv, true, err
+	}
+	inv.Args = args
+	return inv, true, nil
+}
+
+func splitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quoted  bool
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case quoted:
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, ErrUnterminatedQuote
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
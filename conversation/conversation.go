@@ -0,0 +1,343 @@
+// Package conversation runs multi-step dialogs: the bot asks questions one by one
+// and the next message of the user in the chat is taken as answer to the current step.
+// Pressing callback button of the last prompt answers the step with button's callback data
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/api/message"
+	"github.com/s1em0nk3y/vkteams-bot/command"
+	"github.com/s1em0nk3y/vkteams-bot/router"
+)
+
+// End is returned by Step.Next to finish dialog
+const End = ""
+
+const (
+	defaultTimeout       = 10 * time.Minute
+	defaultCancelCommand = "cancel"
+	defaultCancelReply   = "Cancelled"
+)
+
+// Answers of user by step names
+type Answers map[string]string
+
+type Step struct {
+	// Unique name in dialog, answer is saved under it
+	Name string
+	// Text sent when dialog enters the step
+	Prompt string
+	// Keyboard attached to prompt; callback data of pressed button is taken as answer
+	Keyboard *message.KeyboardMarkup
+	// Validate checks answer. Its error is sent to the user and the step is repeated
+	Validate func(answer string) error
+	// Next returns name of the next step or End. Without it dialog goes
+	// to the following step and ends after the last one
+	Next func(answer string, answers Answers) string
+}
+
+type Dialog struct {
+	Name string
+	// The first step starts dialog
+	Steps []Step
+	// OnDone is called when dialog ends with all the answers
+	OnDone func(ctx context.Context, ev event.Event, answers Answers) error
+	// Time to wait for each answer; overrides manager timeout
+	Timeout time.Duration
+}
+
+func (d *Dialog) step(name string) (int, bool) {
+	for i, s := range d.Steps {
+		if s.Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Sender sends prompts and replies, e.g. *vkteams.Bot
+type Sender interface {
+	SendText(ctx context.Context, msg *message.Message) (string, error)
+}
+
+// CallbackAnswerer answers callback queries. If Sender implements it,
+// queries of buttons pressed in dialog are answered
+type CallbackAnswerer interface {
+	AnswerCallback(ctx context.Context, answer *message.AnswerCallback) error
+}
+
+type Manager struct {
+	sender        Sender
+	store         Store
+	timeout       time.Duration
+	cancelCommand string
+	cancelReply   string
+	timeoutReply  string
+	now           func() time.Time
+
+	mu      sync.RWMutex
+	dialogs map[string]*Dialog
+}
+
+type Option func(*Manager)
+
+// WithStore sets state store. By default states are kept in memory
+func WithStore(store Store) Option {
+	return func(m *Manager) {
+		m.store = store
+	}
+}
+
+// WithTimeout sets time to wait for answer, zero disables timeout. Default is 10 minutes.
+// Expired dialogs are dropped when the user writes again or swept by MemoryStore
+func WithTimeout(timeout time.Duration) Option {
+	return func(m *Manager) {
+		m.timeout = timeout
+	}
+}
+
+// WithCancelCommand sets command (without slash) which aborts dialog and reply to it.
+// Default is /cancel
+func WithCancelCommand(name string, reply string) Option {
+	return func(m *Manager) {
+		m.cancelCommand = strings.ToLower(strings.TrimPrefix(name, "/"))
+		m.cancelReply = reply
+	}
+}
+
+// WithTimeoutReply sets text sent when the user answers after timeout, unless the dialog is already swept.
+// The message itself is passed to the next handler then
+func WithTimeoutReply(reply string) Option {
+	return func(m *Manager) {
+		m.timeoutReply = reply
+	}
+}
+
+func New(sender Sender, opts ...Option) *Manager {
+	m := &Manager{
+		sender:        sender,
+		store:         NewMemoryStore(),
+		timeout:       defaultTimeout,
+		cancelCommand: defaultCancelCommand,
+		cancelReply:   defaultCancelReply,
+		now:           time.Now,
+		dialogs:       map[string]*Dialog{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Register adds dialog. Dialog names and step names in dialog must be unique
+func (m *Manager) Register(d Dialog) error {
+	if d.Name == "" || len(d.Steps) == 0 {
+		return fmt.Errorf("dialog must have name and steps")
+	}
+	names := map[string]bool{}
+	for _, s := range d.Steps {
+		if s.Name == End {
+			return fmt.Errorf("dialog %s: step must have name", d.Name)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("dialog %s: step %s is declared twice", d.Name, s.Name)
+		}
+		names[s.Name] = true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.dialogs[d.Name]; ok {
+		return fmt.Errorf("dialog %s is already registered", d.Name)
+	}
+	m.dialogs[d.Name] = &d
+	return nil
+}
+
+// MustRegister is like Register but panics on error
+func (m *Manager) MustRegister(d Dialog) {
+	if err := m.Register(d); err != nil {
+		panic(err)
+	}
+}
+
+// KeyOf returns key of conversation the event belongs to, see event.Event.ChatID
+func KeyOf(ev event.Event) Key {
+	return Key{ChatID: ev.ChatID(), UserID: ev.From.UserID}
+}
+
+// Start begins dialog with author of event, replacing the active one, and sends the first prompt
+func (m *Manager) Start(ctx context.Context, ev event.Event, dialog string) error {
+	m.mu.RLock()
+	d, ok := m.dialogs[dialog]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("dialog %s is not registered", dialog)
+	}
+	return m.enter(ctx, ev, d, &State{Dialog: d.Name, Answers: Answers{}}, 0)
+}
+
+// Cancel drops active dialog of the user in chat
+func (m *Manager) Cancel(ctx context.Context, key Key) error {
+	return m.store.Delete(ctx, key)
+}
+
+// Middleware passes messages and button presses of users with active dialog to the dialog,
+// other events go to the next handler:
+//
+//	r.Use(dialogs.Middleware())
+func (m *Manager) Middleware() router.Middleware {
+	return func(next router.Handler) router.Handler {
+		return func(ctx context.Context, ev event.Event) error {
+			handled, err := m.Handle(ctx, ev)
+			if handled || err != nil {
+				return err
+			}
+			return next(ctx, ev)
+		}
+	}
+}
+
+// Handle takes new message or callback query of the last prompt as answer
+// to the current step of user's dialog. It reports false if the user has no active dialog
+func (m *Manager) Handle(ctx context.Context, ev event.Event) (handled bool, err error) {
+	if ev.Type != event.EventNewMessage && ev.Type != event.EventCallbackQuery {
+		return false, nil
+	}
+	key := KeyOf(ev)
+	state, err := m.store.Get(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("unable to get conversation state: %w", err)
+	}
+	if state == nil {
+		return false, nil
+	}
+	answer := strings.TrimSpace(ev.Text)
+	if ev.Type == event.EventCallbackQuery {
+		// Buttons of other messages do not belong to dialog
+		if state.PromptID == "" || ev.CallbackMessage.MessageID != state.PromptID {
+			return false, nil
+		}
+		if err := m.answerCallback(ctx, ev); err != nil {
+			return true, err
+		}
+		answer = ev.CallbackData
+	}
+	if !state.Expires.IsZero() && !m.now().Before(state.Expires) {
+		if err := m.store.Delete(ctx, key); err != nil {
+			return false, err
+		}
+		if m.timeoutReply != "" {
+			return false, m.reply(ctx, ev, m.timeoutReply)
+		}
+		return false, nil
+	}
+	if inv, ok, _ := command.Parse(ev.Text); ok && ev.Type == event.EventNewMessage && inv.Name == m.cancelCommand {
+		if err := m.store.Delete(ctx, key); err != nil {
+			return true, err
+		}
+		return true, m.reply(ctx, ev, m.cancelReply)
+	}
+
+	m.mu.RLock()
+	d, ok := m.dialogs[state.Dialog]
+	m.mu.RUnlock()
+	i, found := -1, false
+	if ok {
+		i, found = d.step(state.Step)
+	}
+	if !found {
+		// Dialog was changed since the state was saved
+		return false, m.store.Delete(ctx, key)
+	}
+
+	step := d.Steps[i]
+	if step.Validate != nil {
+		if err := step.Validate(answer); err != nil {
+			return true, m.reply(ctx, ev, err.Error())
+		}
+	}
+	if state.Answers == nil {
+		state.Answers = Answers{}
+	}
+	state.Answers[step.Name] = answer
+
+	next := End
+	if step.Next != nil {
+		next = step.Next(answer, state.Answers)
+	} else if i+1 < len(d.Steps) {
+		next = d.Steps[i+1].Name
+	}
+	if next == End {
+		if err := m.store.Delete(ctx, key); err != nil {
+			return true, err
+		}
+		if d.OnDone != nil {
+			return true, d.OnDone(ctx, ev, state.Answers)
+		}
+		return true, nil
+	}
+	j, ok := d.step(next)
+	if !ok {
+		m.store.Delete(ctx, key)
+		return true, fmt.Errorf("dialog %s: unknown step %s", d.Name, next)
+	}
+	return true, m.enter(ctx, ev, d, state, j)
+}
+
+// enter saves state at step i of dialog and sends its prompt
+func (m *Manager) enter(ctx context.Context, ev event.Event, d *Dialog, state *State, i int) error {
+	state.Step = d.Steps[i].Name
+	state.PromptID = ""
+	state.Expires = time.Time{}
+	timeout := m.timeout
+	if d.Timeout > 0 {
+		timeout = d.Timeout
+	}
+	if timeout > 0 {
+		state.Expires = m.now().Add(timeout)
+	}
+	key := KeyOf(ev)
+	if err := m.store.Set(ctx, key, state); err != nil {
+		return fmt.Errorf("unable to save conversation state: %w", err)
+	}
+	if d.Steps[i].Prompt == "" {
+		return nil
+	}
+	msgID, err := m.sender.SendText(ctx, &message.Message{
+		ChatID:         key.ChatID,
+		Text:           d.Steps[i].Prompt,
+		KeyboardMarkup: d.Steps[i].Keyboard,
+	})
+	if err != nil || d.Steps[i].Keyboard == nil {
+		return err
+	}
+	// Remember prompt so its buttons answer the step
+	state.PromptID = msgID
+	if err := m.store.Set(ctx, key, state); err != nil {
+		return fmt.Errorf("unable to save conversation state: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) reply(ctx context.Context, ev event.Event, text string) error {
+	if text == "" {
+		return nil
+	}
+	_, err := m.sender.SendText(ctx, &message.Message{ChatID: KeyOf(ev).ChatID, Text: text})
+	return err
+}
+
+// answerCallback stops loading indicator of pressed button
+func (m *Manager) answerCallback(ctx context.Context, ev event.Event) error {
+	answerer, ok := m.sender.(CallbackAnswerer)
+	if !ok {
+		return nil
+	}
+	return answerer.AnswerCallback(ctx, &message.AnswerCallback{QueryID: ev.QueryID})
+}
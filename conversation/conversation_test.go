@@ -0,0 +1,249 @@
+package conversation_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot"
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/api/message"
+	"github.com/s1em0nk3y/vkteams-bot/conversation"
+	"github.com/s1em0nk3y/vkteams-bot/router"
+	"github.com/s1em0nk3y/vkteams-bot/vkteamstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentMessages []*message.Message
+
+func (s *sentMessages) SendText(ctx context.Context, msg *message.Message) (string, error) {
+	*s = append(*s, msg)
+	return "1", nil
+}
+
+func (s *sentMessages) texts() []string {
+	var texts []string
+	for _, msg := range *s {
+		texts = append(texts, msg.Text)
+	}
+	return texts
+}
+
+func newMessage(chatID, userID, text string) event.Event {
+	ev := event.Event{Type: event.EventNewMessage}
+	ev.Chat.ID = chatID
+	ev.From.UserID = userID
+	ev.Text = text
+	return ev
+}
+
+var incident = conversation.Dialog{
+	Name: "incident",
+	Steps: []conversation.Step{
+		{Name: "service", Prompt: "Which service?"},
+		{
+			Name:   "severity",
+			Prompt: "Severity (1-3)?",
+			Validate: func(answer string) error {
+				if answer < "1" || answer > "3" || len(answer) != 1 {
+					return errors.New("Severity must be 1, 2 or 3")
+				}
+				return nil
+			},
+			Next: func(answer string, answers conversation.Answers) string {
+				if answer == "1" {
+					return "oncall"
+				}
+				return conversation.End
+			},
+		},
+		{Name: "oncall", Prompt: "Who is on call?"},
+	},
+}
+
+func TestManager(t *testing.T) {
+	sent := &sentMessages{}
+	var done []conversation.Answers
+	d := incident
+	d.OnDone = func(ctx context.Context, ev event.Event, answers conversation.Answers) error {
+		done = append(done, answers)
+		return nil
+	}
+	m := conversation.New(sent)
+	require.NoError(t, m.Register(d))
+	assert.Error(t, m.Register(d), "dialog is already registered")
+
+	var passed []string
+	h := router.Chain(func(ctx context.Context, ev event.Event) error {
+		passed = append(passed, ev.Text)
+		return nil
+	}, m.Middleware())
+	ctx := context.Background()
+
+	require.NoError(t, m.Start(ctx, newMessage("chat", "user", "/incident"), "incident"))
+	for _, ev := range []event.Event{
+		newMessage("chat", "other", "not in dialog"),
+		newMessage("chat", "user", " api "),
+		newMessage("chat", "user", "5"),
+		newMessage("chat", "user", "1"),
+		newMessage("chat", "user", "alice"),
+		newMessage("chat", "user", "after dialog"),
+	} {
+		require.NoError(t, h(ctx, ev))
+	}
+	assert.Equal(t, []string{"not in dialog", "after dialog"}, passed)
+	assert.Equal(t, []string{
+		"Which service?", "Severity (1-3)?", "Severity must be 1, 2 or 3", "Who is on call?",
+	}, sent.texts())
+	assert.Equal(t, []conversation.Answers{{"service": "api", "severity": "1", "oncall": "alice"}}, done)
+
+	require.NoError(t, m.Start(ctx, newMessage("chat", "user", ""), "incident"))
+	require.NoError(t, h(ctx, newMessage("chat", "user", "api")))
+	require.NoError(t, h(ctx, newMessage("chat", "user", "2")))
+	assert.Equal(t, conversation.Answers{"service": "api", "severity": "2"}, done[1], "Next ends dialog")
+}
+
+func TestManager_Cancel(t *testing.T) {
+	sent := &sentMessages{}
+	m := conversation.New(sent, conversation.WithCancelCommand("/stop", "Stopped"))
+	m.MustRegister(incident)
+	ctx := context.Background()
+
+	require.NoError(t, m.Start(ctx, newMessage("chat", "user", ""), "incident"))
+	handled, err := m.Handle(ctx, newMessage("chat", "user", "/stop@bot"))
+	assert.NoError(t, err)
+	assert.True(t, handled)
+	handled, err = m.Handle(ctx, newMessage("chat", "user", "api"))
+	assert.NoError(t, err)
+	assert.False(t, handled)
+	assert.Equal(t, []string{"Which service?", "Stopped"}, sent.texts())
+}
+
+func TestManager_Timeout(t *testing.T) {
+	sent := &sentMessages{}
+	store := conversation.NewMemoryStore()
+	m := conversation.New(sent,
+		conversation.WithStore(store),
+		conversation.WithTimeout(time.Millisecond),
+		conversation.WithTimeoutReply("Too late"),
+	)
+	m.MustRegister(incident)
+	ctx := context.Background()
+	key := conversation.Key{ChatID: "chat", UserID: "user"}
+
+	require.NoError(t, m.Start(ctx, newMessage("chat", "user", ""), "incident"))
+	state, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, "service", state.Step)
+
+	time.Sleep(5 * time.Millisecond)
+	handled, err := m.Handle(ctx, newMessage("chat", "user", "api"))
+	assert.NoError(t, err)
+	assert.False(t, handled)
+	assert.Equal(t, []string{"Which service?", "Too late"}, sent.texts())
+	state, err = store.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	store := conversation.NewMemoryStore()
+	ctx := context.Background()
+	abandoned := &conversation.State{Dialog: "incident", Expires: time.Now().Add(-time.Minute)}
+	for i := range 1000 {
+		require.NoError(t, store.Set(ctx, conversation.Key{ChatID: "chat", UserID: strconv.Itoa(i)}, abandoned))
+	}
+	active := &conversation.State{Dialog: "incident", Expires: time.Now().Add(time.Hour)}
+	require.NoError(t, store.Set(ctx, conversation.Key{ChatID: "chat", UserID: "active"}, active))
+	assert.Less(t, store.Len(), 64, "expired states must be swept")
+	state, err := store.Get(ctx, conversation.Key{ChatID: "chat", UserID: "active"})
+	require.NoError(t, err)
+	assert.NotNil(t, state)
+}
+
+func TestManager_Register(t *testing.T) {
+	m := conversation.New(&sentMessages{})
+	assert.Error(t, m.Register(conversation.Dialog{Name: "empty"}))
+	assert.Error(t, m.Register(conversation.Dialog{Name: "unnamed", Steps: []conversation.Step{{Prompt: "?"}}}))
+	assert.Error(t, m.Register(conversation.Dialog{Name: "twice", Steps: []conversation.Step{{Name: "a"}, {Name: "a"}}}))
+	assert.Error(t, m.Start(context.Background(), newMessage("chat", "user", ""), "unknown"))
+}
+
+func TestManager_Keyboard(t *testing.T) {
+	server := vkteamstest.NewServer()
+	defer server.Close()
+	bot := vkteams.New(server.Token, vkteams.WithApiURL(server.URL), vkteams.WithPollSeconds(1),
+		vkteams.WithStartMode(event.StartResume))
+	dialogs := conversation.New(bot)
+	done := make(chan conversation.Answers, 1)
+	dialogs.MustRegister(conversation.Dialog{
+		Name: "deploy",
+		Steps: []conversation.Step{{
+			Name:   "env",
+			Prompt: "Where to deploy?",
+			Keyboard: message.NewKeyboard().
+				Row(message.CallbackButton("Staging", "staging"), message.CallbackButton("Production", "prod")).
+				Markup(),
+		}},
+		OnDone: func(ctx context.Context, ev event.Event, answers conversation.Answers) error {
+			done <- answers
+			return nil
+		},
+	})
+	r := router.New()
+	r.Use(dialogs.Middleware())
+	r.OnNewMessage(func(ctx context.Context, ev event.Event) error {
+		return dialogs.Start(ctx, ev, "deploy")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		r.Run(ctx, bot)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	const chatID = "team@chat.agent"
+	server.SendUserMessage(chatID, "alice", "/deploy")
+	prompt := server.AssertSent(t, chatID, "Where to deploy?")
+	queryID, err := server.PressButton(chatID, prompt.ID, "alice", "Production")
+	require.NoError(t, err)
+	server.AssertAnswered(t, queryID)
+	select {
+	case answers := <-done:
+		assert.Equal(t, conversation.Answers{"env": "prod"}, answers)
+	case <-time.After(2 * time.Second):
+		t.Fatal("dialog is not finished by button")
+	}
+}
+
+func TestManager_CallbackOfOtherMessage(t *testing.T) {
+	sent := &sentMessages{}
+	dialogs := conversation.New(sent)
+	dialogs.MustRegister(conversation.Dialog{
+		Name:  "pick",
+		Steps: []conversation.Step{{Name: "color", Prompt: "Color?", Keyboard: message.NewKeyboard().Row(message.CallbackButton("Red", "red")).Markup()}},
+	})
+	ctx := context.Background()
+	require.NoError(t, dialogs.Start(ctx, newMessage("chat", "user", "/pick"), "pick"))
+
+	ev := event.Event{Type: event.EventCallbackQuery}
+	ev.From.UserID = "user"
+	ev.CallbackMessage.Chat.ID = "chat"
+	ev.CallbackMessage.MessageID = "other"
+	ev.CallbackData = "red"
+	handled, err := dialogs.Handle(ctx, ev)
+	assert.NoError(t, err)
+	assert.False(t, handled, "buttons of other messages must be passed to next handler")
+
+	ev.CallbackMessage.MessageID = "1"
+	handled, err = dialogs.Handle(ctx, ev)
+	assert.NoError(t, err)
+	assert.True(t, handled)
+}
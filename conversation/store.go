@@ -0,0 +1,98 @@
+package conversation
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Key identifies conversation of user in chat
+type Key struct {
+	ChatID string
+	UserID string
+}
+
+// State is position of user in dialog
+type State struct {
+	Dialog  string
+	Step    string
+	Answers Answers
+	// ID of prompt message with keyboard of the current step
+	PromptID string
+	// Dialog is abandoned if user does not answer until this time; zero means never
+	Expires time.Time
+}
+
+// Store keeps conversation states. Implement it to keep dialogs across restarts
+type Store interface {
+	// Get returns nil state if there is no active dialog
+	Get(ctx context.Context, key Key) (*State, error)
+	Set(ctx context.Context, key Key, state *State) error
+	Delete(ctx context.Context, key Key) error
+}
+
+// Number of states after which MemoryStore starts to sweep expired ones
+const minSweepSize = 64
+
+// MemoryStore keeps states in memory. Expired states of abandoned dialogs are swept
+// in Set whenever number of states doubles since the previous sweep
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[Key]State
+	// Size of store which triggers the next sweep
+	sweepAt int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: map[Key]State{}, sweepAt: minSweepSize, now: time.Now}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key Key) (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[key]
+	if !ok {
+		return nil, nil
+	}
+	state.Answers = maps.Clone(state.Answers)
+	return &state, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key Key, state *State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := *state
+	st.Answers = maps.Clone(state.Answers)
+	s.states[key] = st
+	if len(s.states) >= s.sweepAt {
+		s.sweep()
+	}
+	return nil
+}
+
+// sweep removes expired states. Must be called with lock held
+func (s *MemoryStore) sweep() {
+	now := s.now()
+	for key, state := range s.states {
+		if !state.Expires.IsZero() && !now.Before(state.Expires) {
+			delete(s.states, key)
+		}
+	}
+	s.sweepAt = max(2*len(s.states), minSweepSize)
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+// Len returns number of stored states including expired ones not swept yet
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.states)
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
 	"github.com/s1em0nk3y/vkteams-bot"
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
 	"github.com/s1em0nk3y/vkteams-bot/api/message"
+	"github.com/s1em0nk3y/vkteams-bot/router"
 )
 
 var config = struct {
@@ -48,12 +50,12 @@ func main() {
 	)
 	ctx := log.WithContext(context.Background())
 
-	// Listen Events
-	eventChannel := bot.UpdatesChannel(ctx)
-	for event := range eventChannel {
-		_, err := bot.SendText(ctx, &message.Message{
-			ChatID: event.Chat.ID,
-			Text:   "Text | <i>" + event.Text + "</i> | <b>Bold Text</b>",
+	// Route events
+	r := router.New()
+	r.Use(router.LogContext(), router.Recover(), router.Timing())
+	r.OnNewMessage(func(ctx context.Context, ev event.Event) error {
+		msg := &message.Message{
+			ChatID: ev.Chat.ID,
 			KeyboardMarkup: &message.KeyboardMarkup{
 				{
 					{
@@ -72,9 +74,19 @@ func main() {
 					},
 				},
 			},
-			ReplyMsgID: event.MessageID,
-			ParseMode:  message.ParseModeHTML,
+			ReplyMsgID: ev.MessageID,
+		}
+		message.NewText(message.ParseModeHTML).
+			Text("Text | ").Italic(ev.Text).Text(" | ").Bold("Bold Text").
+			Apply(msg)
+		_, err := bot.SendText(ctx, msg)
+		return err
+	})
+	r.OnCallbackQuery(func(ctx context.Context, ev event.Event) error {
+		return bot.AnswerCallback(ctx, &message.AnswerCallback{
+			QueryID: ev.QueryID,
+			Text:    "Pressed " + ev.CallbackData,
 		})
-		log.Err(err).Msg("Send message")
-	}
+	})
+	r.Run(ctx, bot)
 }
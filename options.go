@@ -1,6 +1,12 @@
 package vkteams
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/ratelimit"
+	"github.com/s1em0nk3y/vkteams-bot/retry"
+)
 
 type Option func(*Bot)
 
@@ -23,3 +29,37 @@ func WithPollSeconds(seconds uint) Option {
 		b.pollSeconds = seconds
 	}
 }
+
+// WithRetryPolicy configures retries of failed requests and delays of the event poller.
+// 429 responses are retried for every method, network errors and 5xx only for read-only ones
+// (/events/get, /self/get, /chats/get*, /files/getInfo), so actions are never done twice.
+// Use retry.NoRetry() to send every request once
+func WithRetryPolicy(policy retry.Policy) Option {
+	return func(b *Bot) {
+		b.retryPolicy = policy
+	}
+}
+
+// WithRateLimit paces requests to API: all of them by global limit
+// and requests to the same chat (chatId parameter) by perChat limit.
+// Zero Rate disables corresponding limit
+func WithRateLimit(global ratelimit.Limit, perChat ratelimit.Limit) Option {
+	return func(b *Bot) {
+		b.limiter = ratelimit.New(global, perChat)
+	}
+}
+
+// WithOffsetStore makes UpdatesChannel resume from the last committed event after restart.
+// Events are committed by router after successful handling (or manually with Bot.Commit)
+func WithOffsetStore(store event.OffsetStore) Option {
+	return func(b *Bot) {
+		b.eventOpts = append(b.eventOpts, event.WithOffsetStore(store))
+	}
+}
+
+// WithStartMode sets which events are delivered after start, see event.StartMode
+func WithStartMode(mode event.StartMode) Option {
+	return func(b *Bot) {
+		b.eventOpts = append(b.eventOpts, event.WithStartMode(mode))
+	}
+}
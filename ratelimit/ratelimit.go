@@ -0,0 +1,141 @@
+// Package ratelimit paces outgoing API calls with token buckets:
+// one shared by all requests and one per chat
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limit allows Rate events per second with bursts of at most Burst events.
+// Zero Rate means no limit
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerSecond allows n events per second without bursts
+func PerSecond(n float64) Limit { return Limit{Rate: n, Burst: 1} }
+
+// PerMinute allows n events per minute without bursts
+func PerMinute(n float64) Limit { return Limit{Rate: n / 60, Burst: 1} }
+
+// Bucket is a token bucket. It is safe for concurrent use
+type Bucket struct {
+	mu     sync.Mutex
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func NewBucket(limit Limit) *Bucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &Bucket{limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+}
+
+// Wait blocks until a token is available or context is done
+func (b *Bucket) Wait(ctx context.Context) error {
+	if b == nil || b.limit.Rate <= 0 {
+		return nil
+	}
+	delay := b.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes a token, possibly in debt, and returns time to wait for it
+func (b *Bucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.limit.Rate * float64(time.Second))
+}
+
+// cancel returns reserved token
+func (b *Bucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+1, float64(b.limit.Burst))
+}
+
+// idle reports whether bucket is full, so it may be dropped without changing behavior
+func (b *Bucket) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	return b.tokens >= float64(b.limit.Burst)
+}
+
+func (b *Bucket) advance(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed.Seconds()*b.limit.Rate, float64(b.limit.Burst))
+		b.last = now
+	}
+}
+
+// Number of per chat buckets after which idle ones are dropped
+const sweepThreshold = 1024
+
+// Limiter combines global limit with limit per chat
+type Limiter struct {
+	global  *Bucket
+	perChat Limit
+
+	mu    sync.Mutex
+	chats map[string]*Bucket
+}
+
+func New(global Limit, perChat Limit) *Limiter {
+	return &Limiter{
+		global:  NewBucket(global),
+		perChat: perChat,
+		chats:   map[string]*Bucket{},
+	}
+}
+
+// Wait blocks until request to given chat (may be empty) is allowed or context is done
+func (l *Limiter) Wait(ctx context.Context, chatID string) error {
+	if chatID != "" && l.perChat.Rate > 0 {
+		if err := l.chat(chatID).Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return l.global.Wait(ctx)
+}
+
+func (l *Limiter) chat(chatID string) *Bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.chats[chatID]
+	if ok {
+		return bucket
+	}
+	if len(l.chats) >= sweepThreshold {
+		now := time.Now()
+		for id, b := range l.chats {
+			if b.idle(now) {
+				delete(l.chats, id)
+			}
+		}
+	}
+	bucket = NewBucket(l.perChat)
+	l.chats[chatID] = bucket
+	return bucket
+}
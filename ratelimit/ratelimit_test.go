@@ -0,0 +1,49 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucket_Wait(t *testing.T) {
+	b := ratelimit.NewBucket(ratelimit.Limit{Rate: 50, Burst: 2})
+	start := time.Now()
+	for range 4 {
+		assert.NoError(t, b.Wait(context.Background()))
+	}
+	// 2 tokens are available immediately, 2 more take 20ms each
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 35*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+}
+
+func TestBucket_WaitCanceled(t *testing.T) {
+	b := ratelimit.NewBucket(ratelimit.PerMinute(1))
+	assert.NoError(t, b.Wait(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, b.Wait(ctx), context.DeadlineExceeded)
+}
+
+func TestBucket_Unlimited(t *testing.T) {
+	b := ratelimit.NewBucket(ratelimit.Limit{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 100 {
+		assert.NoError(t, b.Wait(ctx))
+	}
+}
+
+func TestLimiter_PerChat(t *testing.T) {
+	l := ratelimit.New(ratelimit.Limit{}, ratelimit.PerMinute(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, l.Wait(ctx, "chat1"))
+	assert.NoError(t, l.Wait(ctx, "chat2"))
+	assert.NoError(t, l.Wait(ctx, ""))
+	assert.ErrorIs(t, l.Wait(ctx, "chat1"), context.DeadlineExceeded)
+}
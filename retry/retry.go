@@ -0,0 +1,90 @@
+// Package retry describes retry policy with exponential backoff and jitter,
+// shared by the bot HTTP client and the event poller
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultBaseDelay = 500 * time.Millisecond
+	defaultMaxDelay  = 30 * time.Second
+)
+
+type Policy struct {
+	// Max number of attempts including the first one; values <= 1 disable retries
+	MaxAttempts int
+	// Delay before the first retry, doubled on every next one (default 500ms)
+	BaseDelay time.Duration
+	// Upper bound of a single delay (default 30s).
+	// Requests asking to wait longer via Retry-After are not retried
+	MaxDelay time.Duration
+	// Fraction of delay (0..1) which is randomized
+	Jitter float64
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 3,
+		BaseDelay:   defaultBaseDelay,
+		MaxDelay:    defaultMaxDelay,
+		Jitter:      0.5,
+	}
+}
+
+// NoRetry makes exactly one attempt
+func NoRetry() Policy { return Policy{MaxAttempts: 1} }
+
+// Delay returns time to wait after given failed attempt (starting from 1)
+func (p Policy) Delay(attempt int) time.Duration {
+	base, maxDelay := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = defaultBaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxDelay
+	}
+	delay := base
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		delay -= time.Duration(rand.Float64() * jitter * float64(delay))
+	}
+	return delay
+}
+
+// RetryAfter returns delay requested by server, if it fits MaxDelay
+func (p Policy) RetryAfter(requested time.Duration) (time.Duration, bool) {
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxDelay
+	}
+	return requested, requested <= maxDelay
+}
+
+// Retryable reports whether the result of request is worth retrying:
+// network errors, 5xx and 429 responses
+func Retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// Sleep waits for given duration or until context is done
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,69 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Delay(t *testing.T) {
+	policy := retry.Policy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: 2 * time.Second},
+		{attempt: 3, want: 4 * time.Second},
+		{attempt: 4, want: 5 * time.Second},
+		{attempt: 100, want: 5 * time.Second},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, policy.Delay(tt.attempt), "attempt %d", tt.attempt)
+	}
+}
+
+func TestPolicy_DelayJitter(t *testing.T) {
+	policy := retry.Policy{BaseDelay: time.Second, MaxDelay: time.Minute, Jitter: 0.5}
+	for range 100 {
+		delay := policy.Delay(2)
+		assert.GreaterOrEqual(t, delay, time.Second)
+		assert.LessOrEqual(t, delay, 2*time.Second)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{name: "Network error", err: errors.New("connection reset"), want: true},
+		{name: "Canceled", err: context.Canceled},
+		{name: "Deadline", err: context.DeadlineExceeded},
+		{name: "Ok", resp: &http.Response{StatusCode: http.StatusOK}},
+		{name: "Not found", resp: &http.Response{StatusCode: http.StatusNotFound}},
+		{name: "Too many requests", resp: &http.Response{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "Bad gateway", resp: &http.Response{StatusCode: http.StatusBadGateway}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, retry.Retryable(tt.resp, tt.err))
+		})
+	}
+}
+
+func TestSleep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	assert.ErrorIs(t, retry.Sleep(ctx, time.Minute), context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.NoError(t, retry.Sleep(context.Background(), time.Millisecond))
+}
@@ -0,0 +1,55 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type lastCommitter struct {
+	last  int
+	calls int
+}
+
+func (c *lastCommitter) Commit(ctx context.Context, eventID int) error {
+	c.last = eventID
+	c.calls++
+	return nil
+}
+
+func TestCommitTracker_FailureFollowedBySuccesses(t *testing.T) {
+	committer := &lastCommitter{}
+	tracker := newCommitTracker(committer)
+	ctx := context.Background()
+	for id := 1; id <= 10000; id++ {
+		tracker.add(id)
+		if id == 5 {
+			tracker.fail(ctx, id)
+			continue
+		}
+		tracker.complete(ctx, id)
+	}
+	assert.Equal(t, 4, committer.last)
+	assert.Equal(t, 4, committer.calls)
+	assert.Empty(t, tracker.pending)
+	assert.Empty(t, tracker.done)
+}
+
+func TestCommitTracker_FailureWhileEarlierEventsInFlight(t *testing.T) {
+	committer := &lastCommitter{}
+	tracker := newCommitTracker(committer)
+	ctx := context.Background()
+	for id := 1; id <= 5; id++ {
+		tracker.add(id)
+	}
+	tracker.complete(ctx, 3)
+	tracker.fail(ctx, 4)
+	tracker.complete(ctx, 5)
+	assert.Equal(t, 0, committer.calls)
+	tracker.complete(ctx, 2)
+	tracker.complete(ctx, 1)
+	assert.Equal(t, 3, committer.last)
+	assert.Empty(t, tracker.pending)
+	assert.Empty(t, tracker.done)
+}
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+)
+
+// Middleware wraps handler with cross-cutting logic
+type Middleware func(Handler) Handler
+
+// Chain wraps handler with middlewares; the first one is the outermost
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Use adds middlewares wrapping every handler of the router (including fallback).
+// Middlewares are applied in order of addition, the first one is the outermost
+func (r *Router) Use(mws ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, mws...)
+}
+
+// PanicError is returned by Recover middleware when handler panics
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("handler panicked: %v", e.Value) }
+
+// Recover turns panics of handler into *PanicError and logs them with stack trace
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ev event.Event) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					stack := debug.Stack()
+					zerolog.Ctx(ctx).Error().
+						Interface("panic", v).
+						Bytes("stack", stack).
+						Msg("handler panicked")
+					err = &PanicError{Value: v, Stack: stack}
+				}
+			}()
+			return next(ctx, ev)
+		}
+	}
+}
+
+// Timing logs duration of every handled event
+func Timing() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ev event.Event) error {
+			start := time.Now()
+			err := next(ctx, ev)
+			zerolog.Ctx(ctx).Debug().
+				Err(err).
+				Dur("duration", time.Since(start)).
+				Msg("event handled")
+			return err
+		}
+	}
+}
+
+// LogContext adds event id, type, chat id and user id fields to zerolog logger of context
+func LogContext() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, ev event.Event) error {
+			log := zerolog.Ctx(ctx).With().
+				Int("event_id", ev.ID).
+				Str("event_type", string(ev.Type)).
+				Str("chat_id", ev.ChatID()).
+				Str("user_id", ev.From.UserID).
+				Logger()
+			return next(log.WithContext(ctx), ev)
+		}
+	}
+}
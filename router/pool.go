@@ -0,0 +1,286 @@
+package router
+
+import (
+	"context"
+	"hash/fnv"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+)
+
+// Backpressure defines what happens to event when queue of its chat is full
+type Backpressure int
+
+const (
+	// Block reading of new events until queue has free space
+	BackpressureBlock Backpressure = iota
+	// Drop event which does not fit queue
+	BackpressureDrop
+)
+
+const defaultDrainTimeout = 30 * time.Second
+
+// Pool handles events concurrently. Events are sharded between workers by chat,
+// so events of the same chat are handled one by one in order of arrival
+type Pool struct {
+	handler      Handler
+	onError      ErrorHandler
+	onDrop       func(ctx context.Context, ev event.Event)
+	workers      int
+	queueSize    int
+	backpressure Backpressure
+	drainTimeout time.Duration
+	committer    event.Committer
+}
+
+type PoolOption func(*Pool)
+
+// WithWorkers sets number of workers (default is runtime.NumCPU())
+func WithWorkers(n int) PoolOption {
+	return func(p *Pool) {
+		if n > 0 {
+			p.workers = n
+		}
+	}
+}
+
+// WithQueueSize sets number of events waiting for every worker (default 64)
+func WithQueueSize(n int) PoolOption {
+	return func(p *Pool) {
+		if n >= 0 {
+			p.queueSize = n
+		}
+	}
+}
+
+// WithBackpressure sets behavior for full queues (default BackpressureBlock).
+// onDrop (may be nil) is called for every dropped event
+func WithBackpressure(b Backpressure, onDrop func(ctx context.Context, ev event.Event)) PoolOption {
+	return func(p *Pool) {
+		p.backpressure = b
+		if onDrop != nil {
+			p.onDrop = onDrop
+		}
+	}
+}
+
+// WithDrainTimeout limits time given to queued events after context is done (default 30s).
+// Once it is over, context of handlers is canceled. Zero means no limit
+func WithDrainTimeout(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.drainTimeout = d
+	}
+}
+
+// WithPoolErrorHandler sets hook for handler errors (default logs them)
+func WithPoolErrorHandler(h ErrorHandler) PoolOption {
+	return func(p *Pool) {
+		p.onError = h
+	}
+}
+
+// WithCommitter commits events once they and all events received before them are handled successfully.
+// The first failed or dropped event stops commits until restart, so it is delivered again with all later events
+func WithCommitter(c event.Committer) PoolOption {
+	return func(p *Pool) {
+		p.committer = c
+	}
+}
+
+func NewPool(handler Handler, opts ...PoolOption) *Pool {
+	p := &Pool{
+		handler:      handler,
+		onError:      logError,
+		onDrop:       logDrop,
+		workers:      runtime.NumCPU(),
+		queueSize:    64,
+		drainTimeout: defaultDrainTimeout,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Run handles events until channel is closed or context is done.
+// Then it stops reading events, waits for queued ones and returns
+func (p *Pool) Run(ctx context.Context, events <-chan event.Event) error {
+	handlerCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	stopDrain := context.AfterFunc(ctx, func() {
+		if p.drainTimeout > 0 {
+			time.AfterFunc(p.drainTimeout, cancel)
+		}
+	})
+	defer stopDrain()
+
+	tracker := newCommitTracker(p.committer)
+	queues := make([]chan event.Event, p.workers)
+	wg := sync.WaitGroup{}
+	for i := range queues {
+		queues[i] = make(chan event.Event, p.queueSize)
+		wg.Add(1)
+		go func(queue <-chan event.Event) {
+			defer wg.Done()
+			for ev := range queue {
+				if err := p.handler(handlerCtx, ev); err != nil {
+					p.onError(handlerCtx, ev, err)
+					tracker.fail(handlerCtx, ev.ID)
+					continue
+				}
+				tracker.complete(handlerCtx, ev.ID)
+			}
+		}(queues[i])
+	}
+
+	p.feed(ctx, events, queues, tracker)
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (p *Pool) feed(ctx context.Context, events <-chan event.Event, queues []chan event.Event, tracker *commitTracker) {
+	for {
+		var ev event.Event
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			ev = e
+		}
+		tracker.add(ev.ID)
+		queue := queues[shard(ev.ChatID(), len(queues))]
+		if p.backpressure == BackpressureDrop {
+			select {
+			case queue <- ev:
+			default:
+				p.onDrop(ctx, ev)
+				tracker.fail(ctx, ev.ID)
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case queue <- ev:
+		}
+	}
+}
+
+// commitTracker commits the highest event ID such that all events up to it are handled successfully.
+// Failure is permanent: after the first failed or dropped event nothing is committed anymore,
+// so that event and all later ones are delivered again after restart. Events received after
+// the failure are not tracked, so memory use is bounded by number of events in flight
+type commitTracker struct {
+	committer event.Committer
+	mu        sync.Mutex
+	// IDs of uncommitted events in order of arrival
+	pending []int
+	// Handled events from pending
+	done map[int]bool
+	// ID of the first failed event; 0 if there was no failure
+	failed int
+}
+
+func newCommitTracker(committer event.Committer) *commitTracker {
+	return &commitTracker{committer: committer, done: map[int]bool{}}
+}
+
+func (t *commitTracker) add(eventID int) {
+	if t.committer == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failed == 0 {
+		t.pending = append(t.pending, eventID)
+	}
+}
+
+func (t *commitTracker) complete(ctx context.Context, eventID int) {
+	if t.committer == nil {
+		return
+	}
+	// Commit is done under lock, so IDs are committed in increasing order
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !slices.Contains(t.pending, eventID) {
+		return
+	}
+	t.done[eventID] = true
+	last := -1
+	for len(t.pending) > 0 && t.done[t.pending[0]] {
+		last = t.pending[0]
+		delete(t.done, last)
+		t.pending = t.pending[1:]
+	}
+	if last < 0 {
+		return
+	}
+	if err := t.committer.Commit(ctx, last); err != nil {
+		zerolog.Ctx(ctx).Err(err).Int("event_id", last).Msg("unable to commit event")
+	}
+}
+
+// fail stops commits at the event preceding failed one
+func (t *commitTracker) fail(ctx context.Context, eventID int) {
+	if t.committer == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := slices.Index(t.pending, eventID)
+	if i < 0 {
+		return
+	}
+	if t.failed == 0 {
+		zerolog.Ctx(ctx).Warn().Int("event_id", eventID).
+			Msg("event is not handled; commits are stopped until restart")
+	}
+	t.failed = eventID
+	for _, id := range t.pending[i:] {
+		delete(t.done, id)
+	}
+	t.pending = t.pending[:i]
+}
+
+func shard(chatID string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(chatID))
+	return int(h.Sum32() % uint32(n))
+}
+
+func logDrop(ctx context.Context, ev event.Event) {
+	zerolog.Ctx(ctx).Warn().
+		Int("event_id", ev.ID).
+		Str("event_type", string(ev.Type)).
+		Msg("queue is full; event dropped")
+}
+
+// RunConcurrent is like Run but handles events with a Pool.
+// Router's error hook is used unless WithPoolErrorHandler is given.
+// If source implements event.Committer, handled events are committed;
+// if it implements event.Stopper, error which stopped it is returned
+func (r *Router) RunConcurrent(ctx context.Context, src Source, opts ...PoolOption) error {
+	r.mu.RLock()
+	onError := r.onError
+	r.mu.RUnlock()
+	defaults := []PoolOption{WithPoolErrorHandler(onError)}
+	if committer, ok := src.(event.Committer); ok {
+		defaults = append(defaults, WithCommitter(committer))
+	}
+	opts = append(defaults, opts...)
+	if err := NewPool(r.Handle, opts...).Run(ctx, src.UpdatesChannel(ctx)); err != nil {
+		return err
+	}
+	return sourceErr(ctx, src)
+}
@@ -0,0 +1,165 @@
+package router_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/router"
+	"github.com/stretchr/testify/assert"
+)
+
+func chatEvent(id int, chatID string) event.Event {
+	ev := event.Event{ID: id, Type: event.EventNewMessage}
+	ev.Chat.ID = chatID
+	return ev
+}
+
+func TestPool_PerChatOrder(t *testing.T) {
+	mu := sync.Mutex{}
+	handled := map[string][]int{}
+	var running, maxRunning atomic.Int32
+	p := router.NewPool(func(ctx context.Context, ev event.Event) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		handled[ev.Chat.ID] = append(handled[ev.Chat.ID], ev.ID)
+		return nil
+	}, router.WithWorkers(4), router.WithQueueSize(2))
+
+	events := make(chan event.Event)
+	go func() {
+		defer close(events)
+		for i := range 40 {
+			events <- chatEvent(i, fmt.Sprintf("chat%d", i%8))
+		}
+	}()
+	assert.NoError(t, p.Run(context.Background(), events))
+
+	for chatID, ids := range handled {
+		assert.IsIncreasing(t, ids, chatID)
+		assert.Len(t, ids, 5, chatID)
+	}
+	assert.Greater(t, maxRunning.Load(), int32(1), "events must be handled concurrently")
+}
+
+func TestPool_Drop(t *testing.T) {
+	release := make(chan struct{})
+	var dropped []int
+	p := router.NewPool(func(ctx context.Context, ev event.Event) error {
+		<-release
+		return nil
+	},
+		router.WithWorkers(1),
+		router.WithQueueSize(1),
+		router.WithBackpressure(router.BackpressureDrop, func(ctx context.Context, ev event.Event) {
+			dropped = append(dropped, ev.ID)
+		}),
+	)
+	events := make(chan event.Event)
+	go func() {
+		events <- chatEvent(1, "chat") // taken by worker
+		time.Sleep(10 * time.Millisecond)
+		events <- chatEvent(2, "chat") // queued
+		events <- chatEvent(3, "chat") // dropped
+		close(release)
+		close(events)
+	}()
+	assert.NoError(t, p.Run(context.Background(), events))
+	assert.Equal(t, []int{3}, dropped)
+}
+
+func TestPool_Drain(t *testing.T) {
+	var handled atomic.Int32
+	started := make(chan struct{}, 3)
+	p := router.NewPool(func(ctx context.Context, ev event.Event) error {
+		started <- struct{}{}
+		time.Sleep(20 * time.Millisecond)
+		assert.NoError(t, ctx.Err(), "handlers must not be canceled during drain")
+		handled.Add(1)
+		return nil
+	}, router.WithWorkers(1), router.WithQueueSize(4))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan event.Event, 3)
+	for i := range 3 {
+		events <- chatEvent(i, "chat")
+	}
+	go func() {
+		<-started
+		cancel()
+	}()
+	assert.ErrorIs(t, p.Run(ctx, events), context.Canceled)
+	assert.Positive(t, handled.Load())
+	// One of start signals is consumed by canceling goroutine
+	assert.Equal(t, int32(len(started)+1), handled.Load(), "all started events must be finished")
+}
+
+func TestPool_DrainTimeout(t *testing.T) {
+	p := router.NewPool(func(ctx context.Context, ev event.Event) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, router.WithDrainTimeout(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan event.Event, 1)
+	events <- chatEvent(1, "chat")
+	time.AfterFunc(10*time.Millisecond, cancel)
+	done := make(chan error)
+	go func() { done <- p.Run(ctx, events) }()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("pool is not stopped after drain timeout")
+	}
+}
+
+type recordingCommitter struct {
+	mu  sync.Mutex
+	ids []int
+}
+
+func (c *recordingCommitter) Commit(ctx context.Context, eventID int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ids = append(c.ids, eventID)
+	return nil
+}
+
+func TestPool_Commit(t *testing.T) {
+	committer := &recordingCommitter{}
+	p := router.NewPool(func(ctx context.Context, ev event.Event) error {
+		if ev.ID == 4 {
+			return errors.New("failed")
+		}
+		// Later events of other chats finish first
+		time.Sleep(time.Duration(10-ev.ID) * time.Millisecond)
+		return nil
+	},
+		router.WithWorkers(3),
+		router.WithCommitter(committer),
+		router.WithPoolErrorHandler(func(ctx context.Context, ev event.Event, err error) {}),
+	)
+	events := make(chan event.Event, 6)
+	for i := 1; i <= 6; i++ {
+		events <- chatEvent(i, fmt.Sprintf("chat%d", i))
+	}
+	close(events)
+	assert.NoError(t, p.Run(context.Background(), events))
+	assert.NotEmpty(t, committer.ids)
+	assert.IsIncreasing(t, committer.ids)
+	assert.Equal(t, 3, committer.ids[len(committer.ids)-1], "failed event must hold back commits")
+}
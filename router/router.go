@@ -0,0 +1,144 @@
+// Package router dispatches events from UpdatesChannel to handlers registered per event type
+package router
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+)
+
+// Handler processes single event
+type Handler func(ctx context.Context, ev event.Event) error
+
+// ErrorHandler is called when handler returns an error
+type ErrorHandler func(ctx context.Context, ev event.Event, err error)
+
+// Source of events, e.g. *vkteams.Bot or *event.EventService
+type Source interface {
+	UpdatesChannel(ctx context.Context) <-chan event.Event
+}
+
+type Router struct {
+	mu          sync.RWMutex
+	handlers    map[event.EventType]Handler
+	fallback    Handler
+	unknown     Handler
+	onError     ErrorHandler
+	middlewares []Middleware
+}
+
+func New() *Router {
+	return &Router{
+		handlers: map[event.EventType]Handler{},
+		onError:  logError,
+	}
+}
+
+// On registers handler for events of given type, replacing previous one
+func (r *Router) On(eventType event.EventType, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[eventType] = h
+}
+
+func (r *Router) OnNewMessage(h Handler)      { r.On(event.EventNewMessage, h) }
+func (r *Router) OnEditedMessage(h Handler)   { r.On(event.EventEditedMessage, h) }
+func (r *Router) OnDeletedMessage(h Handler)  { r.On(event.EventDeletedMessage, h) }
+func (r *Router) OnPinnedMessage(h Handler)   { r.On(event.EventPinnedMessage, h) }
+func (r *Router) OnUnpinnedMessage(h Handler) { r.On(event.EventUnpinnedMessage, h) }
+func (r *Router) OnNewChatMembers(h Handler)  { r.On(event.EventNewChatMembers, h) }
+func (r *Router) OnLeftChatMembers(h Handler) { r.On(event.EventLeftChatMembers, h) }
+func (r *Router) OnChangedChatInfo(h Handler) { r.On(event.EventChangedChatInfo, h) }
+func (r *Router) OnCallbackQuery(h Handler)   { r.On(event.EventCallbackQuery, h) }
+
+// OnUnknown registers handler for events of types unknown to the library (see EventType.Known).
+// Use event.Raw to access their contents. Without it such events go to fallback
+func (r *Router) OnUnknown(h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unknown = h
+}
+
+// Fallback registers handler for events without own handler
+func (r *Router) Fallback(h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = h
+}
+
+// OnError replaces error hook. By default errors are logged with zerolog logger from context
+func (r *Router) OnError(h ErrorHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onError = h
+}
+
+// Handle dispatches event to its handler through middlewares.
+// Events without handler are passed through middlewares too and ignored
+func (r *Router) Handle(ctx context.Context, ev event.Event) error {
+	r.mu.RLock()
+	h, ok := r.handlers[ev.Type]
+	if !ok && !ev.Type.Known() {
+		h = r.unknown
+	}
+	if h == nil {
+		h = r.fallback
+	}
+	mws := r.middlewares
+	r.mu.RUnlock()
+	if h == nil {
+		h = ignore
+	}
+	return Chain(h, mws...)(ctx, ev)
+}
+
+func ignore(context.Context, event.Event) error { return nil }
+
+// Run reads events from source and handles them one by one until context is done.
+// If source implements event.Committer, successfully handled events are committed.
+// The first failed event stops commits until restart, like in RunConcurrent.
+// If source implements event.Stopper, error which stopped it is returned
+func (r *Router) Run(ctx context.Context, src Source) error {
+	committer, _ := src.(event.Committer)
+	tracker := newCommitTracker(committer)
+	for ev := range src.UpdatesChannel(ctx) {
+		tracker.add(ev.ID)
+		if r.dispatch(ctx, ev) {
+			tracker.complete(ctx, ev.ID)
+		} else {
+			tracker.fail(ctx, ev.ID)
+		}
+	}
+	return sourceErr(ctx, src)
+}
+
+// sourceErr returns error which stopped source, if it reports one, or error of context
+func sourceErr(ctx context.Context, src Source) error {
+	if stopper, ok := src.(event.Stopper); ok {
+		if err := stopper.UpdatesErr(); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// dispatch handles event and reports whether it succeeded
+func (r *Router) dispatch(ctx context.Context, ev event.Event) bool {
+	if err := r.Handle(ctx, ev); err != nil {
+		r.mu.RLock()
+		onError := r.onError
+		r.mu.RUnlock()
+		onError(ctx, ev, err)
+		return false
+	}
+	return true
+}
+
+func logError(ctx context.Context, ev event.Event, err error) {
+	zerolog.Ctx(ctx).Err(err).
+		Int("event_id", ev.ID).
+		Str("event_type", string(ev.Type)).
+		Msg("unable to handle event")
+}
@@ -0,0 +1,194 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/s1em0nk3y/vkteams-bot"
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/router"
+	"github.com/s1em0nk3y/vkteams-bot/vkteamstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticSource sends predefined events and closes channel
+type staticSource []event.Event
+
+func (s staticSource) UpdatesChannel(ctx context.Context) <-chan event.Event {
+	ch := make(chan event.Event, len(s))
+	for _, ev := range s {
+		ch <- ev
+	}
+	close(ch)
+	return ch
+}
+
+func TestRouter_Run(t *testing.T) {
+	var handled []string
+	record := func(name string) router.Handler {
+		return func(ctx context.Context, ev event.Event) error {
+			handled = append(handled, name)
+			return nil
+		}
+	}
+	var failed []int
+	r := router.New()
+	r.OnNewMessage(record("new"))
+	r.OnCallbackQuery(record("callback"))
+	r.OnEditedMessage(func(ctx context.Context, ev event.Event) error {
+		return errors.New("edit failed")
+	})
+	r.Fallback(record("fallback"))
+	r.OnError(func(ctx context.Context, ev event.Event, err error) {
+		assert.EqualError(t, err, "edit failed")
+		failed = append(failed, ev.ID)
+	})
+
+	err := r.Run(context.Background(), staticSource{
+		{ID: 1, Type: event.EventNewMessage},
+		{ID: 2, Type: event.EventEditedMessage},
+		{ID: 3, Type: event.EventCallbackQuery},
+		{ID: 4, Type: event.EventPinnedMessage},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"new", "callback", "fallback"}, handled)
+	assert.Equal(t, []int{2}, failed)
+}
+
+// committingSource is staticSource which records committed IDs
+type committingSource struct {
+	staticSource
+	recordingCommitter
+}
+
+func TestRouter_RunCommit(t *testing.T) {
+	r := router.New()
+	r.OnNewMessage(func(ctx context.Context, ev event.Event) error {
+		if ev.ID == 2 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	r.OnError(func(ctx context.Context, ev event.Event, err error) {})
+	src := &committingSource{staticSource: staticSource{
+		{ID: 1, Type: event.EventNewMessage},
+		{ID: 2, Type: event.EventNewMessage},
+		{ID: 3, Type: event.EventNewMessage},
+	}}
+	assert.NoError(t, r.Run(context.Background(), src))
+	assert.Equal(t, []int{1}, src.ids, "failed event must hold back commits")
+}
+
+func TestRouter_HandleWithoutHandler(t *testing.T) {
+	r := router.New()
+	assert.NoError(t, r.Handle(context.Background(), event.Event{Type: event.EventDeletedMessage}))
+}
+
+func TestRouter_Use(t *testing.T) {
+	var calls []string
+	mw := func(name string) router.Middleware {
+		return func(next router.Handler) router.Handler {
+			return func(ctx context.Context, ev event.Event) error {
+				calls = append(calls, name)
+				return next(ctx, ev)
+			}
+		}
+	}
+	r := router.New()
+	r.Use(mw("first"), mw("second"))
+	r.OnNewMessage(func(ctx context.Context, ev event.Event) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+	assert.NoError(t, r.Handle(context.Background(), event.Event{Type: event.EventNewMessage}))
+	assert.Equal(t, []string{"first", "second", "handler"}, calls)
+}
+
+func TestRecover(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx := zerolog.New(buf).WithContext(context.Background())
+	h := router.Chain(func(ctx context.Context, ev event.Event) error {
+		panic("boom")
+	}, router.LogContext(), router.Recover())
+	ev := event.Event{ID: 7, Type: event.EventNewMessage}
+	ev.Chat.ID = "chat"
+	ev.From.UserID = "user"
+
+	err := h(ctx, ev)
+	var panicErr *router.PanicError
+	if assert.ErrorAs(t, err, &panicErr) {
+		assert.Equal(t, "boom", panicErr.Value)
+		assert.NotEmpty(t, panicErr.Stack)
+	}
+	logged := map[string]any{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &logged))
+	assert.Equal(t, "boom", logged["panic"])
+	assert.Equal(t, float64(7), logged["event_id"])
+	assert.Equal(t, "chat", logged["chat_id"])
+	assert.Equal(t, "user", logged["user_id"])
+	assert.NotEmpty(t, logged["stack"])
+}
+
+func TestTiming(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx := zerolog.New(buf).WithContext(context.Background())
+	h := router.Chain(func(ctx context.Context, ev event.Event) error {
+		return errors.New("failed")
+	}, router.Timing())
+	assert.EqualError(t, h(ctx, event.Event{}), "failed")
+	assert.Contains(t, buf.String(), `"duration":`)
+	assert.Contains(t, buf.String(), `"error":"failed"`)
+}
+
+func TestRouter_OnUnknown(t *testing.T) {
+	var unknown, fallback []event.EventType
+	r := router.New()
+	r.Fallback(func(ctx context.Context, ev event.Event) error {
+		fallback = append(fallback, ev.Type)
+		return nil
+	})
+	ctx := context.Background()
+	assert.NoError(t, r.Handle(ctx, event.Event{Type: "newReaction"}))
+	r.OnUnknown(func(ctx context.Context, ev event.Event) error {
+		unknown = append(unknown, ev.Type)
+		return nil
+	})
+	assert.NoError(t, r.Handle(ctx, event.Event{Type: "newReaction"}))
+	assert.NoError(t, r.Handle(ctx, event.Event{Type: event.EventPinnedMessage}))
+	assert.Equal(t, []event.EventType{"newReaction"}, unknown)
+	assert.Equal(t, []event.EventType{"newReaction", event.EventPinnedMessage}, fallback)
+}
+
+func TestRouter_RunSourceError(t *testing.T) {
+	server := vkteamstest.NewServer()
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "offset")
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0o644))
+	bot := vkteams.New(server.Token, vkteams.WithApiURL(server.URL),
+		vkteams.WithOffsetStore(event.NewFileOffsetStore(path)))
+
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, r *router.Router) error
+	}{
+		{name: "Run", run: func(ctx context.Context, r *router.Router) error { return r.Run(ctx, bot) }},
+		{name: "RunConcurrent", run: func(ctx context.Context, r *router.Router) error { return r.RunConcurrent(ctx, bot) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := tt.run(ctx, router.New())
+			assert.ErrorContains(t, err, "unable to load offset")
+			assert.NoError(t, ctx.Err(), "router must stop because of the error")
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type fileEntry struct {
+	Values  Values    `json:"values"`
+	Expires time.Time `json:"expires"`
+}
+
+// FileStore keeps sessions in memory and in a JSON file, which is rewritten atomically
+// on every change. It suits bots with moderate number of sessions
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]fileEntry
+	now     func() time.Time
+}
+
+// NewFileStore loads sessions from file; missing file means no sessions
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, entries: map[string]fileEntry{}, now: time.Now}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read sessions: %w", err)
+	}
+	if err = json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("unable to parse sessions: %w", err)
+	}
+	return s, nil
+}
+
+func (s *FileStore) Load(_ context.Context, key string) (Values, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || (!e.Expires.IsZero() && !s.now().Before(e.Expires)) {
+		return nil, nil
+	}
+	return maps.Clone(e.Values), nil
+}
+
+func (s *FileStore) Save(_ context.Context, key string, values Values, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, existed := s.entries[key]
+	s.entries[key] = fileEntry{Values: maps.Clone(values), Expires: expiresAt(s.now(), ttl)}
+	if err := s.flush(); err != nil {
+		if existed {
+			s.entries[key] = prev
+		} else {
+			delete(s.entries, key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	delete(s.entries, key)
+	if err := s.flush(); err != nil {
+		s.entries[key] = prev
+		return err
+	}
+	return nil
+}
+
+// flush drops expired sessions and writes the rest to file
+func (s *FileStore) flush() error {
+	now := s.now()
+	maps.DeleteFunc(s.entries, func(_ string, e fileEntry) bool {
+		return !e.Expires.IsZero() && !now.Before(e.Expires)
+	})
+	data, err := json.Marshal(s.entries)
+	if err != nil {
+		return fmt.Errorf("unable to save sessions: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to save sessions: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to save sessions: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("unable to save sessions: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("unable to save sessions: %w", err)
+	}
+	return nil
+}
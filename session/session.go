@@ -0,0 +1,243 @@
+// Package session keeps per-user and per-chat key/value state of handlers in a pluggable store
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/router"
+)
+
+// Session is a set of named values loaded from store on first access.
+// Values are encoded as JSON, so any serializable type can be kept
+type Session struct {
+	key   string
+	store Store
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	loaded  bool
+	changed bool
+	values  Values
+}
+
+// Key returns store key of session
+func (s *Session) Key() string { return s.key }
+
+// Get decodes value into v and reports whether it exists
+func (s *Session) Get(ctx context.Context, name string, v any) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(ctx); err != nil {
+		return false, err
+	}
+	data, ok := s.values[name]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return true, fmt.Errorf("unable to decode session value %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// Set stores value; it is written to store by Save
+func (s *Session) Set(ctx context.Context, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("unable to encode session value %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+	s.values[name] = data
+	s.changed = true
+	return nil
+}
+
+// Delete removes value; it is written to store by Save
+func (s *Session) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+	if _, ok := s.values[name]; ok {
+		delete(s.values, name)
+		s.changed = true
+	}
+	return nil
+}
+
+// Clear removes all the values
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loaded, s.changed = true, true
+	s.values = Values{}
+}
+
+// Save writes changed session to store, prolonging its TTL.
+// Empty session is deleted from store
+func (s *Session) Save(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.changed {
+		return nil
+	}
+	var err error
+	if len(s.values) == 0 {
+		err = s.store.Delete(ctx, s.key)
+	} else {
+		err = s.store.Save(ctx, s.key, s.values, s.ttl)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to save session %s: %w", s.key, err)
+	}
+	s.changed = false
+	return nil
+}
+
+func (s *Session) load(ctx context.Context) error {
+	if s.loaded {
+		return nil
+	}
+	values, err := s.store.Load(ctx, s.key)
+	if err != nil {
+		return fmt.Errorf("unable to load session %s: %w", s.key, err)
+	}
+	if values == nil {
+		values = Values{}
+	}
+	s.values, s.loaded = values, true
+	return nil
+}
+
+type Manager struct {
+	store Store
+	ttl   time.Duration
+	locks keyLocks
+}
+
+type Option func(*Manager)
+
+// WithTTL makes sessions expire after given time without changes
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Manager) {
+		m.ttl = ttl
+	}
+}
+
+func New(store Store, opts ...Option) *Manager {
+	m := &Manager{store: store, locks: keyLocks{locks: map[string]*keyLock{}}}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Session returns session by store key, e.g. to access it outside of handlers
+func (m *Manager) Session(key string) *Session {
+	return &Session{key: key, store: m.store, ttl: m.ttl}
+}
+
+// User returns session of user with given ID
+func (m *Manager) User(userID string) *Session { return m.Session("user:" + userID) }
+
+// Chat returns session of chat with given ID
+func (m *Manager) Chat(chatID string) *Session { return m.Session("chat:" + chatID) }
+
+type contextKey struct{}
+
+type sessions struct {
+	user, chat *Session
+}
+
+// Middleware puts sessions of event's user and chat into handler context
+// (see User and Chat) and saves them after handler returns.
+// Handlers of events sharing a session run one at a time, so concurrent events
+// of the same user in different chats (router.RunConcurrent) do not overwrite each other's changes.
+// Sessions obtained from Manager.Session, User or Chat are not locked
+func (m *Manager) Middleware() router.Middleware {
+	return func(next router.Handler) router.Handler {
+		return func(ctx context.Context, ev event.Event) error {
+			s := &sessions{}
+			// User session is always locked before chat one, so locks can not deadlock
+			if ev.From.UserID != "" {
+				s.user = m.User(ev.From.UserID)
+				defer m.locks.lock(s.user.key)()
+			}
+			if chatID := ev.ChatID(); chatID != "" {
+				s.chat = m.Chat(chatID)
+				defer m.locks.lock(s.chat.key)()
+			}
+			err := next(context.WithValue(ctx, contextKey{}, s), ev)
+			for _, session := range []*Session{s.user, s.chat} {
+				if session == nil {
+					continue
+				}
+				if saveErr := session.Save(ctx); saveErr != nil && err == nil {
+					err = saveErr
+				}
+			}
+			return err
+		}
+	}
+}
+
+// User returns session of the user who initiated event. It is nil outside of Middleware
+// or if event has no user
+func User(ctx context.Context) *Session {
+	if s, ok := ctx.Value(contextKey{}).(*sessions); ok {
+		return s.user
+	}
+	return nil
+}
+
+// Chat returns session of the chat of event. It is nil outside of Middleware
+// or if event has no chat
+func Chat(ctx context.Context) *Session {
+	if s, ok := ctx.Value(contextKey{}).(*sessions); ok {
+		return s.chat
+	}
+	return nil
+}
+
+// keyLocks is a set of mutexes by session key; unused mutexes are removed
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock locks key and returns function unlocking it
+func (l *keyLocks) lock(key string) (unlock func()) {
+	l.mu.Lock()
+	kl, ok := l.locks[key]
+	if !ok {
+		kl = &keyLock{}
+		l.locks[key] = kl
+	}
+	kl.refs++
+	l.mu.Unlock()
+
+	kl.mu.Lock()
+	return func() {
+		kl.mu.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if kl.refs--; kl.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
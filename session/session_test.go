@@ -0,0 +1,181 @@
+package session_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	fileStore, err := session.NewFileStore(filepath.Join(t.TempDir(), "sessions.json"))
+	require.NoError(t, err)
+	stores := map[string]session.Store{
+		"Memory": session.NewMemoryStore(0),
+		"File":   fileStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			values, err := store.Load(ctx, "user:1")
+			assert.NoError(t, err)
+			assert.Nil(t, values)
+
+			want := session.Values{"lang": json.RawMessage(`"ru"`)}
+			require.NoError(t, store.Save(ctx, "user:1", want, 0))
+			require.NoError(t, store.Save(ctx, "user:2", want, time.Millisecond))
+			values, err = store.Load(ctx, "user:1")
+			assert.NoError(t, err)
+			assert.Equal(t, want, values)
+
+			time.Sleep(5 * time.Millisecond)
+			values, err = store.Load(ctx, "user:2")
+			assert.NoError(t, err)
+			assert.Nil(t, values, "session expired")
+
+			require.NoError(t, store.Delete(ctx, "user:1"))
+			values, err = store.Load(ctx, "user:1")
+			assert.NoError(t, err)
+			assert.Nil(t, values)
+		})
+	}
+}
+
+func TestMemoryStore_Eviction(t *testing.T) {
+	ctx := context.Background()
+	store := session.NewMemoryStore(2)
+	values := session.Values{"v": json.RawMessage(`1`)}
+	require.NoError(t, store.Save(ctx, "a", values, 0))
+	require.NoError(t, store.Save(ctx, "b", values, 0))
+	_, err := store.Load(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, "c", values, 0))
+
+	assert.Equal(t, 2, store.Len())
+	b, _ := store.Load(ctx, "b")
+	assert.Nil(t, b, "least recently used session is evicted")
+	a, _ := store.Load(ctx, "a")
+	assert.NotNil(t, a)
+
+	require.NoError(t, store.Save(ctx, "d", values, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, store.Save(ctx, "e", values, 0))
+	a, _ = store.Load(ctx, "a")
+	assert.NotNil(t, a, "expired sessions are evicted first")
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store, err := session.NewFileStore(path)
+	require.NoError(t, err)
+	values := session.Values{"step": json.RawMessage(`"confirm"`)}
+	require.NoError(t, store.Save(ctx, "chat:1", values, time.Hour))
+
+	reopened, err := session.NewFileStore(path)
+	require.NoError(t, err)
+	got, err := reopened.Load(ctx, "chat:1")
+	assert.NoError(t, err)
+	assert.Equal(t, values, got)
+
+	_, err = session.NewFileStore(t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	store := session.NewMemoryStore(0)
+	m := session.New(store, session.WithTTL(time.Hour))
+	ev := event.Event{Type: event.EventNewMessage}
+	ev.Chat.ID = "chat"
+	ev.From.UserID = "user"
+
+	type prefs struct{ Lang string }
+	h := m.Middleware()(func(ctx context.Context, ev event.Event) error {
+		var p prefs
+		ok, err := session.User(ctx).Get(ctx, "prefs", &p)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			p.Lang = "en"
+		}
+		p.Lang += "!"
+		if err := session.User(ctx).Set(ctx, "prefs", p); err != nil {
+			return err
+		}
+		return session.Chat(ctx).Set(ctx, "last_user", ev.From.UserID)
+	})
+	require.NoError(t, h(ctx, ev))
+	require.NoError(t, h(ctx, ev))
+
+	var p prefs
+	ok, err := m.User("user").Get(ctx, "prefs", &p)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, prefs{Lang: "en!!"}, p)
+	var last string
+	ok, err = m.Chat("chat").Get(ctx, "last_user", &last)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "user", last)
+
+	assert.Nil(t, session.User(ctx), "no session outside of middleware")
+
+	s := m.Chat("chat")
+	s.Clear()
+	require.NoError(t, s.Save(ctx))
+	values, _ := store.Load(ctx, "chat:chat")
+	assert.Nil(t, values, "empty session is deleted")
+}
+
+func TestMiddleware_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	m := session.New(session.NewMemoryStore(0))
+	h := m.Middleware()(func(ctx context.Context, ev event.Event) error {
+		var count int
+		if _, err := session.User(ctx).Get(ctx, "count", &count); err != nil {
+			return err
+		}
+		// Let other events of the user read the same value, if they are not serialized
+		time.Sleep(time.Millisecond)
+		return session.User(ctx).Set(ctx, "count", count+1)
+	})
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ev := event.Event{Type: event.EventNewMessage}
+			ev.Chat.ID = fmt.Sprintf("chat%d", i)
+			ev.From.UserID = "user"
+			assert.NoError(t, h(ctx, ev))
+		}()
+	}
+	wg.Wait()
+	var count int
+	_, err := m.User("user").Get(ctx, "count", &count)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, count, "changes of concurrent events must not be lost")
+}
+
+type failingStore struct{ session.Store }
+
+func (failingStore) Load(context.Context, string) (session.Values, error) {
+	return nil, errors.New("store is down")
+}
+
+func TestSession_StoreError(t *testing.T) {
+	m := session.New(failingStore{})
+	_, err := m.User("user").Get(context.Background(), "prefs", &struct{}{})
+	assert.ErrorContains(t, err, "unable to load session user:user: store is down")
+}
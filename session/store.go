@@ -0,0 +1,128 @@
+package session
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Values of session by name, encoded as JSON
+type Values map[string]json.RawMessage
+
+// Store keeps sessions by key. Implementations must be safe for concurrent use
+type Store interface {
+	// Load returns nil if session does not exist or has expired
+	Load(ctx context.Context, key string) (Values, error)
+	// Save replaces session values; ttl <= 0 means session never expires
+	Save(ctx context.Context, key string, values Values, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+type entry struct {
+	key     string
+	values  Values
+	expires time.Time
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// MemoryStore keeps sessions in memory. When it is full,
+// expired sessions are removed first and then the least recently used ones
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	// Front is the most recently used
+	lru *list.List
+	now func() time.Time
+}
+
+// NewMemoryStore creates store for at most maxEntries sessions; 0 means no limit
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+func (s *MemoryStore) Load(_ context.Context, key string) (Values, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	e := el.Value.(*entry)
+	if e.expired(s.now()) {
+		s.remove(el)
+		return nil, nil
+	}
+	s.lru.MoveToFront(el)
+	return maps.Clone(e.values), nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, key string, values Values, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := &entry{key: key, values: maps.Clone(values), expires: expiresAt(s.now(), ttl)}
+	if el, ok := s.entries[key]; ok {
+		el.Value = e
+		s.lru.MoveToFront(el)
+		return nil
+	}
+	s.entries[key] = s.lru.PushFront(e)
+	s.evict()
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// Len returns number of stored sessions including expired ones not evicted yet
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+func (s *MemoryStore) evict() {
+	if s.maxEntries <= 0 || s.lru.Len() <= s.maxEntries {
+		return
+	}
+	now := s.now()
+	for el := s.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if el.Value.(*entry).expired(now) {
+			s.remove(el)
+		}
+		el = prev
+	}
+	for s.lru.Len() > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.lru.Remove(el)
+	delete(s.entries, el.Value.(*entry).key)
+}
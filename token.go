@@ -0,0 +1,72 @@
+package vkteams
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Replaces token in errors returned by the bot
+const redactedToken = "REDACTED"
+
+// tokenTransport adds token to requests to API just before sending them,
+// so URLs of requests, errors of http.Client and logs do not contain it
+type tokenTransport struct {
+	base   http.RoundTripper
+	apiUrl *url.URL
+	token  string
+}
+
+// withToken returns copy of cli which authenticates requests to API at apiUrl
+func withToken(cli *http.Client, apiUrl *url.URL, token string) *http.Client {
+	base := cli.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	withToken := *cli
+	withToken.Transport = &tokenTransport{base: base, apiUrl: apiUrl, token: token}
+	return &withToken
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isAPIRequest(t.apiUrl, req.URL) {
+		return t.base.RoundTrip(req)
+	}
+	authorized := req.Clone(req.Context())
+	params := authorized.URL.Query()
+	params.Set("token", t.token)
+	authorized.URL.RawQuery = params.Encode()
+	resp, err := t.base.RoundTrip(authorized)
+	if resp != nil {
+		resp.Request = req
+	}
+	return resp, redact(err, t.token)
+}
+
+// isAPIRequest reports whether u has the same scheme and host as apiUrl
+// and its path is apiUrl path or lies under it
+func isAPIRequest(apiUrl *url.URL, u *url.URL) bool {
+	if apiUrl == nil || !strings.EqualFold(u.Scheme, apiUrl.Scheme) || !strings.EqualFold(u.Host, apiUrl.Host) {
+		return false
+	}
+	base := strings.TrimSuffix(apiUrl.Path, "/")
+	return u.Path == base || strings.HasPrefix(u.Path, base+"/")
+}
+
+// redactedError hides token in message of wrapped error; errors.Is and errors.As still see the original
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redact replaces token in message of err
+func redact(err error, token string) error {
+	if err == nil || token == "" || !strings.Contains(err.Error(), token) {
+		return err
+	}
+	return &redactedError{err: err, msg: strings.ReplaceAll(err.Error(), token, redactedToken)}
+}
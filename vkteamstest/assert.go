@@ -0,0 +1,85 @@
+package vkteamstest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/message"
+)
+
+// AssertSent waits until the bot sends message with given text to chat and returns it.
+// The test fails if it does not happen within AssertTimeout
+func (s *Server) AssertSent(t testing.TB, chatID string, text string) Message {
+	t.Helper()
+	var found *Message
+	ok := s.waitFor(context.Background(), s.AssertTimeout, func() bool {
+		for _, msg := range s.messages {
+			if msg.ChatID == chatID && msg.From == s.bot.UserID && !msg.Deleted && msg.Text == text {
+				found = msg
+				return true
+			}
+		}
+		return false
+	})
+	if !ok {
+		t.Errorf("message %q was not sent to chat %s; sent messages: %v", text, chatID, texts(s.SentMessages(chatID)))
+		return Message{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return found.clone()
+}
+
+// AssertNotSent checks that the bot has not sent message with given text to chat.
+// It does not wait, so check it after the bot has done its work
+func (s *Server) AssertNotSent(t testing.TB, chatID string, text string) {
+	t.Helper()
+	for _, msg := range s.SentMessages(chatID) {
+		if msg.Text == text {
+			t.Errorf("message %q was sent to chat %s", text, chatID)
+			return
+		}
+	}
+}
+
+// AssertCalled waits until API method with given path is called and returns the first such call
+func (s *Server) AssertCalled(t testing.TB, path string) Request {
+	t.Helper()
+	var found Request
+	ok := s.waitFor(context.Background(), s.AssertTimeout, func() bool {
+		for _, req := range s.requests {
+			if req.Path == path {
+				found = req
+				return true
+			}
+		}
+		return false
+	})
+	if !ok {
+		t.Errorf("%s was not called", path)
+	}
+	return found
+}
+
+// AssertAnswered waits until the bot answers callback query and returns the answer
+func (s *Server) AssertAnswered(t testing.TB, queryID string) message.AnswerCallback {
+	t.Helper()
+	ok := s.waitFor(context.Background(), s.AssertTimeout, func() bool {
+		query, ok := s.queries[queryID]
+		return ok && query.Answer != nil
+	})
+	if !ok {
+		t.Errorf("callback query %s was not answered", queryID)
+		return message.AnswerCallback{}
+	}
+	answer, _ := s.CallbackAnswer(queryID)
+	return answer
+}
+
+func texts(messages []Message) []string {
+	result := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		result = append(result, msg.Text)
+	}
+	return result
+}
@@ -0,0 +1,145 @@
+// Package cassette records interactions with VK Teams Bot API to a file once
+// and replays them in tests without network and token:
+//
+//	// Record: requests go to real API, cassette is written when the test ends
+//	rec := cassette.NewRecorder(t, "testdata/send_text.json")
+//	bot := vkteams.New(os.Getenv("VK_TOKEN"), vkteams.WithHTTPClient(rec.Client()))
+//
+//	// Replay: responses are served from cassette, unmatched requests fail the test
+//	rep := cassette.NewReplayer(t, "testdata/send_text.json")
+//	bot := vkteams.New("token", vkteams.WithHTTPClient(rep.Client()))
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redacted replaces token in recorded requests and responses
+const Redacted = "REDACTED"
+
+// Request is a recorded API call. Bodies of requests (file uploads) are not recorded
+type Request struct {
+	Method string     `json:"method"`
+	Path   string     `json:"path"`
+	Params url.Values `json:"params,omitempty"`
+}
+
+func (r Request) String() string {
+	if len(r.Params) == 0 {
+		return r.Method + " " + r.Path
+	}
+	return r.Method + " " + r.Path + "?" + r.Params.Encode()
+}
+
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+	// "base64" if Body is binary, empty otherwise
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load reads cassette from file
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cassette: %w", err)
+	}
+	c := &Cassette{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("unable to decode cassette %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Save writes cassette to file, creating missing directories
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("unable to create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("unable to write cassette: %w", err)
+	}
+	return nil
+}
+
+// newRequest converts HTTP request to recorded form with token redacted
+func newRequest(req *http.Request) Request {
+	params := req.URL.Query()
+	if params.Has("token") {
+		params.Set("token", Redacted)
+	}
+	return Request{Method: req.Method, Path: req.URL.Path, Params: params}
+}
+
+// newResponse reads body of resp and converts it to recorded form,
+// replacing token with Redacted. Body of resp is replaced so it can be read again
+func newResponse(resp *http.Response, token string) (Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return Response{}, fmt.Errorf("unable to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	recorded := Response{Status: resp.StatusCode, Header: resp.Header.Clone()}
+	if !utf8.Valid(body) {
+		recorded.Body, recorded.Encoding = base64.StdEncoding.EncodeToString(body), "base64"
+		return recorded, nil
+	}
+	recorded.Body = string(body)
+	if token != "" {
+		recorded.Body = strings.ReplaceAll(recorded.Body, token, Redacted)
+		for _, values := range recorded.Header {
+			for i := range values {
+				values[i] = strings.ReplaceAll(values[i], token, Redacted)
+			}
+		}
+	}
+	return recorded, nil
+}
+
+// httpResponse builds response to req from recorded one
+func (r Response) httpResponse(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Body)
+	if r.Encoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(r.Body); err != nil {
+			return nil, fmt.Errorf("unable to decode recorded body: %w", err)
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package cassette_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/s1em0nk3y/vkteams-bot"
+	"github.com/s1em0nk3y/vkteams-bot/api/message"
+	"github.com/s1em0nk3y/vkteams-bot/retry"
+	"github.com/s1em0nk3y/vkteams-bot/vkteamstest"
+	"github.com/s1em0nk3y/vkteams-bot/vkteamstest/cassette"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errorsT records errors instead of failing the test
+type errorsT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *errorsT) Error(args ...any) {
+	t.errors = append(t.errors, fmt.Sprint(args...))
+}
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "cassette.json")
+	server := vkteamstest.NewServer(vkteamstest.WithToken("secret-token"))
+	fileID := server.AddFile("binary.bin", "file", []byte{0xff, 0x00, 0xfe})
+
+	t.Run("Record", func(t *testing.T) {
+		rec := cassette.NewRecorder(t, path)
+		bot := vkteams.New(server.Token, vkteams.WithApiURL(server.URL), vkteams.WithHTTPClient(rec.Client()))
+		ctx := context.Background()
+		_, err := bot.SendText(ctx, &message.Message{ChatID: "chat", Text: "first"})
+		require.NoError(t, err)
+		_, err = bot.SendText(ctx, &message.Message{ChatID: "chat", Text: "second", ReplyMsgID: "unknown"})
+		require.ErrorIs(t, err, message.ErrNotOk)
+		body, _, err := bot.Download(ctx, fileID)
+		require.NoError(t, err)
+		body.Close()
+	})
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-token")
+	assert.Contains(t, string(data), cassette.Redacted)
+	c, err := cassette.Load(path)
+	require.NoError(t, err)
+	require.Len(t, c.Interactions, 4)
+	assert.Equal(t, "/messages/sendText", c.Interactions[0].Request.Path)
+	assert.Equal(t, "base64", c.Interactions[3].Response.Encoding)
+
+	// Replayed requests must not reach the server
+	server.Close()
+	ctx := context.Background()
+	t.Run("Replay", func(t *testing.T) {
+		rep := cassette.NewReplayer(t, path)
+		bot := vkteams.New("other-token", vkteams.WithApiURL(server.URL), vkteams.WithHTTPClient(rep.Client()))
+		// Recorded order is kept among matching interactions only
+		_, err := bot.SendText(ctx, &message.Message{ChatID: "chat", Text: "second", ReplyMsgID: "unknown"})
+		assert.ErrorContains(t, err, "Invalid replyMsgId")
+		msgID, err := bot.SendText(ctx, &message.Message{ChatID: "chat", Text: "first"})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, msgID)
+		body, info, err := bot.Download(ctx, fileID)
+		require.NoError(t, err)
+		defer body.Close()
+		contents, _ := io.ReadAll(body)
+		assert.Equal(t, []byte{0xff, 0x00, 0xfe}, contents)
+		assert.Equal(t, "binary.bin", info.Filename)
+		assert.Empty(t, rep.Unused())
+	})
+
+	t.Run("Unmatched", func(t *testing.T) {
+		recorder := &errorsT{TB: t}
+		rep := cassette.NewReplayer(recorder, path)
+		bot := vkteams.New("token", vkteams.WithApiURL(server.URL), vkteams.WithHTTPClient(rep.Client()),
+			vkteams.WithRetryPolicy(retry.NoRetry()))
+		_, err := bot.SendText(ctx, &message.Message{ChatID: "chat", Text: "third"})
+		assert.ErrorIs(t, err, cassette.ErrUnmatched)
+		require.Len(t, recorder.errors, 1)
+		assert.Contains(t, recorder.errors[0], "GET /messages/sendText?")
+		assert.Len(t, rep.Unused(), 4)
+	})
+
+	t.Run("Custom matchers", func(t *testing.T) {
+		rep := cassette.NewReplayer(t, path, cassette.WithMatchers(cassette.MatchMethod, cassette.MatchPath,
+			cassette.MatchParamsExcept("text")))
+		bot := vkteams.New("token", vkteams.WithApiURL(server.URL), vkteams.WithHTTPClient(rep.Client()))
+		_, err := bot.SendText(ctx, &message.Message{ChatID: "chat", Text: "third"})
+		assert.NoError(t, err)
+		assert.Len(t, rep.Unused(), 3)
+	})
+}
+
+func TestMatchParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      string
+		recorded string
+		want     bool
+	}{
+		{name: "Same", req: "a=1&b=2", recorded: "a=1&b=2", want: true},
+		{name: "Order of params", req: "b=2&a=1", recorded: "a=1&b=2", want: true},
+		{name: "Order of values", req: "a=2&a=1", recorded: "a=1&a=2", want: true},
+		{name: "Token ignored", req: "a=1&token=secret", recorded: "a=1&token=" + cassette.Redacted, want: true},
+		{name: "Different value", req: "a=1", recorded: "a=2", want: false},
+		{name: "Missing param", req: "a=1", recorded: "a=1&b=2", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cassette.MatchParams(request(tt.req), request(tt.recorded)))
+		})
+	}
+}
+
+func request(query string) cassette.Request {
+	params, _ := url.ParseQuery(query)
+	return cassette.Request{Method: "GET", Path: "/", Params: params}
+}
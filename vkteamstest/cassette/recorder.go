@@ -0,0 +1,79 @@
+package cassette
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// Recorder is http.RoundTripper which passes requests to real transport
+// and records them with responses. Cassette is saved when the test ends
+type Recorder struct {
+	transport http.RoundTripper
+	path      string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+type Option func(*options)
+
+type options struct {
+	transport http.RoundTripper
+	matchers  []Matcher
+}
+
+// WithTransport sets transport used by Recorder to send requests; default is http.DefaultTransport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// NewRecorder creates Recorder which writes cassette to path when t ends.
+// Existing cassette is overwritten
+func NewRecorder(t testing.TB, path string, opts ...Option) *Recorder {
+	o := newOptions(opts)
+	r := &Recorder{transport: o.transport, path: path}
+	t.Cleanup(func() {
+		if err := r.Save(); err != nil {
+			t.Error(err)
+		}
+	})
+	return r
+}
+
+func newOptions(opts []Option) options {
+	o := options{transport: http.DefaultTransport, matchers: DefaultMatchers()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := newResponse(resp, req.URL.Query().Get("token"))
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{Request: newRequest(req), Response: recorded})
+	return resp, nil
+}
+
+// Client returns HTTP client for vkteams.WithHTTPClient
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Save writes interactions recorded so far to cassette file
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.Save(r.path)
+}
@@ -0,0 +1,145 @@
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+)
+
+var ErrUnmatched = errors.New("no recorded interaction matches request")
+
+// Matcher reports whether request matches recorded one
+type Matcher func(req Request, recorded Request) bool
+
+func MatchMethod(req Request, recorded Request) bool {
+	return req.Method == recorded.Method
+}
+
+func MatchPath(req Request, recorded Request) bool {
+	return req.Path == recorded.Path
+}
+
+// MatchParams compares params ignoring their order. Token is never compared
+func MatchParams(req Request, recorded Request) bool {
+	return MatchParamsExcept()(req, recorded)
+}
+
+// MatchParamsExcept compares params ignoring their order and given names,
+// e.g. lastEventId of /events/get
+func MatchParamsExcept(names ...string) Matcher {
+	ignored := append([]string{"token"}, names...)
+	return func(req Request, recorded Request) bool {
+		return maps.EqualFunc(withoutParams(req, ignored), withoutParams(recorded, ignored), func(a, b []string) bool {
+			return slices.Equal(slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b)))
+		})
+	}
+}
+
+func withoutParams(req Request, names []string) map[string][]string {
+	params := maps.Clone(req.Params)
+	for _, name := range names {
+		delete(params, name)
+	}
+	return params
+}
+
+// DefaultMatchers compare method, path and params
+func DefaultMatchers() []Matcher {
+	return []Matcher{MatchMethod, MatchPath, MatchParams}
+}
+
+// WithMatchers sets how Replayer matches requests to recorded ones; see DefaultMatchers
+func WithMatchers(matchers ...Matcher) Option {
+	return func(o *options) {
+		o.matchers = matchers
+	}
+}
+
+// Replayer is http.RoundTripper which serves responses from cassette.
+// Every recorded interaction is served once, in recorded order among matching ones.
+// Request without unused matching interaction fails the test and returns ErrUnmatched
+type Replayer struct {
+	t        testing.TB
+	path     string
+	matchers []Matcher
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+	// Set when test ends: t must not be used after that
+	done bool
+}
+
+// NewReplayer loads cassette from path; the test fails immediately if it can not be loaded
+func NewReplayer(t testing.TB, path string, opts ...Option) *Replayer {
+	t.Helper()
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := newOptions(opts)
+	r := &Replayer{
+		t:            t,
+		path:         path,
+		matchers:     o.matchers,
+		interactions: c.Interactions,
+		used:         make([]bool, len(c.Interactions)),
+	}
+	t.Cleanup(func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.done = true
+	})
+	return r
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	recorded := newRequest(req)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if !r.used[i] && r.match(recorded, interaction.Request) {
+			r.used[i] = true
+			return interaction.Response.httpResponse(req)
+		}
+	}
+	err := fmt.Errorf("%w: %s (cassette %s)", ErrUnmatched, recorded, r.path)
+	if !r.done {
+		r.t.Error(err)
+	}
+	return nil, err
+}
+
+func (r *Replayer) match(req Request, recorded Request) bool {
+	for _, match := range r.matchers {
+		if !match(req, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// Unused returns recorded requests which were not served yet
+func (r *Replayer) Unused() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Request
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction.Request)
+		}
+	}
+	return unused
+}
+
+// Client returns HTTP client for vkteams.WithHTTPClient
+func (r *Replayer) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
@@ -0,0 +1,278 @@
+package vkteamstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/chat"
+)
+
+// Number of members returned by /chats/getMembers at once
+const membersPageSize = 100
+
+// Chat state kept by the server. Chats are created on first use;
+// IDs ending with @chat.agent are groups, others are private chats
+type Chat struct {
+	ID      string
+	Info    chat.ChatInfo
+	Members []chat.Member
+	// IDs of blocked users
+	Blocked []string
+	// IDs of users waiting for approval to join
+	Pending []string
+	// IDs of pinned messages
+	Pinned []string
+}
+
+func (c *Chat) clone() Chat {
+	clone := *c
+	clone.Members = slices.Clone(c.Members)
+	clone.Blocked = slices.Clone(c.Blocked)
+	clone.Pending = slices.Clone(c.Pending)
+	clone.Pinned = slices.Clone(c.Pinned)
+	return clone
+}
+
+func (c *Chat) member(userID string) int {
+	return slices.IndexFunc(c.Members, func(m chat.Member) bool { return m.UserID == userID })
+}
+
+func chatMember(userID string) chat.Member { return chat.Member{UserID: userID} }
+
+func (c *Chat) removeMember(userID string) bool {
+	if i := c.member(userID); i >= 0 {
+		c.Members = slices.Delete(c.Members, i, i+1)
+		return true
+	}
+	return false
+}
+
+// AddChat adds or replaces chat
+func (s *Server) AddChat(c Chat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clone := c.clone()
+	if clone.Info.Type == "" {
+		clone.Info.Type = chatType(c.ID)
+	}
+	s.chats[c.ID] = &clone
+	s.notify()
+}
+
+// Chat returns copy of chat state
+func (s *Server) Chat(chatID string) (Chat, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return Chat{}, false
+	}
+	return c.clone(), true
+}
+
+func chatType(chatID string) chat.ChatType {
+	if strings.HasSuffix(chatID, "@chat.agent") {
+		return chat.ChatTypeGroup
+	}
+	return chat.ChatTypePrivate
+}
+
+// chat returns existing chat or creates new one. Must be called with lock held
+func (s *Server) chat(chatID string) *Chat {
+	c, ok := s.chats[chatID]
+	if !ok {
+		c = &Chat{ID: chatID, Info: chat.ChatInfo{Type: chatType(chatID)}}
+		s.chats[chatID] = c
+	}
+	return c
+}
+
+// chatCall runs f with chat from chatId param under lock
+func (s *Server) chatCall(params url.Values, f func(c *Chat) (map[string]any, string), names ...string) (map[string]any, string) {
+	if missing := required(params, append([]string{"chatId"}, names...)...); missing != "" {
+		return nil, missing
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	response, description := f(s.chat(params.Get("chatId")))
+	if description == "" {
+		s.notify()
+	}
+	return response, description
+}
+
+func users(ids []string) []chat.User {
+	result := make([]chat.User, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, chat.User{UserID: id})
+	}
+	return result
+}
+
+func (s *Server) chatsGetInfo(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		var response map[string]any
+		data, _ := json.Marshal(c.Info)
+		json.Unmarshal(data, &response)
+		return response, ""
+	})
+}
+
+func (s *Server) chatsGetAdmins(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		admins := []chat.Admin{}
+		for _, m := range c.Members {
+			if m.Admin || m.Creator {
+				admins = append(admins, chat.Admin{UserID: m.UserID, Creator: m.Creator})
+			}
+		}
+		return map[string]any{"admins": admins}, ""
+	})
+}
+
+func (s *Server) chatsGetMembers(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		start := 0
+		if cursor := params.Get("cursor"); cursor != "" {
+			var err error
+			if start, err = strconv.Atoi(cursor); err != nil || start < 0 || start > len(c.Members) {
+				return nil, "Invalid cursor"
+			}
+		}
+		end := min(start+membersPageSize, len(c.Members))
+		response := map[string]any{"members": append([]chat.Member{}, c.Members[start:end]...)}
+		if end < len(c.Members) {
+			response["cursor"] = strconv.Itoa(end)
+		}
+		return response, ""
+	})
+}
+
+func (s *Server) chatsGetBlockedUsers(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		return map[string]any{"users": users(c.Blocked)}, ""
+	})
+}
+
+func (s *Server) chatsGetPendingUsers(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		return map[string]any{"users": users(c.Pending)}, ""
+	})
+}
+
+func (s *Server) chatsBlockUser(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		userID := params.Get("userId")
+		c.removeMember(userID)
+		if !slices.Contains(c.Blocked, userID) {
+			c.Blocked = append(c.Blocked, userID)
+		}
+		if params.Get("delLastMessages") == "true" {
+			for _, msg := range s.messages {
+				if msg.ChatID == c.ID && msg.From == userID {
+					msg.Deleted = true
+				}
+			}
+		}
+		return nil, ""
+	}, "userId")
+}
+
+func (s *Server) chatsUnblockUser(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		c.Blocked = slices.DeleteFunc(c.Blocked, func(id string) bool { return id == params.Get("userId") })
+		return nil, ""
+	}, "userId")
+}
+
+func (s *Server) chatsResolvePending(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		userID, everyone := params.Get("userId"), params.Get("everyone") == "true"
+		if (userID == "") == !everyone {
+			return nil, "Exactly one of userId or everyone must be set"
+		}
+		var resolved []string
+		c.Pending = slices.DeleteFunc(c.Pending, func(id string) bool {
+			if everyone || id == userID {
+				resolved = append(resolved, id)
+				return true
+			}
+			return false
+		})
+		if len(resolved) == 0 {
+			return nil, "User is not pending"
+		}
+		if params.Get("approve") == "true" {
+			for _, id := range resolved {
+				c.Members = append(c.Members, chatMember(id))
+			}
+		}
+		return nil, ""
+	})
+}
+
+func (s *Server) chatsDeleteMembers(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		var members []struct {
+			Sn string `json:"sn"`
+		}
+		if err := json.Unmarshal([]byte(params.Get("members")), &members); err != nil {
+			return nil, "Invalid members: " + err.Error()
+		}
+		for _, m := range members {
+			if !c.removeMember(m.Sn) {
+				return nil, "User " + m.Sn + " is not a member"
+			}
+		}
+		return nil, ""
+	}, "members")
+}
+
+func (s *Server) chatsSetTitle(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		c.Info.Title = params.Get("title")
+		return nil, ""
+	}, "title")
+}
+
+func (s *Server) chatsSetAbout(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		c.Info.About = params.Get("about")
+		return nil, ""
+	})
+}
+
+func (s *Server) chatsSetRules(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		c.Info.Rules = params.Get("rules")
+		return nil, ""
+	})
+}
+
+func (s *Server) chatsPinMessage(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		msgID := params.Get("msgId")
+		if s.message(c.ID, msgID) == nil {
+			return nil, "Message not found"
+		}
+		if !slices.Contains(c.Pinned, msgID) {
+			c.Pinned = append(c.Pinned, msgID)
+		}
+		return nil, ""
+	}, "msgId")
+}
+
+func (s *Server) chatsUnpinMessage(_ *http.Request, params url.Values) (map[string]any, string) {
+	return s.chatCall(params, func(c *Chat) (map[string]any, string) {
+		msgID := params.Get("msgId")
+		if !slices.Contains(c.Pinned, msgID) {
+			return nil, "Message is not pinned"
+		}
+		c.Pinned = slices.DeleteFunc(c.Pinned, func(id string) bool { return id == msgID })
+		return nil, ""
+	}, "msgId")
+}
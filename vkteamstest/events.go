@@ -0,0 +1,170 @@
+package vkteamstest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/api/message"
+)
+
+// CallbackQuery is a simulated button press
+type CallbackQuery struct {
+	ID     string
+	ChatID string
+	MsgID  string
+	UserID string
+	Data   string
+	// Answer is set when bot calls /messages/answerCallbackQuery
+	Answer *message.AnswerCallback
+}
+
+// PushEvent adds event with given type and payload to the queue of /events/get
+// and returns it with assigned ID
+func (s *Server) PushEvent(eventType event.EventType, payload event.Payload) event.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pushEvent(eventType, payload)
+}
+
+// pushEvent must be called with lock held
+func (s *Server) pushEvent(eventType event.EventType, payload event.Payload) event.Event {
+	ev := event.Event{ID: len(s.events) + 1, Type: eventType, Payload: payload}
+	s.events = append(s.events, ev)
+	s.notify()
+	return ev
+}
+
+// Events returns all the events pushed so far
+func (s *Server) Events() []event.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]event.Event(nil), s.events...)
+}
+
+// basePayload describes message for events. Must be called with lock held
+func (s *Server) basePayload(msg *Message) event.BasePayload {
+	c := s.chat(msg.ChatID)
+	return event.BasePayload{
+		MessageID: msg.ID,
+		Chat:      event.Chat{ID: c.ID, Type: event.ChatType(c.Info.Type), Title: c.Info.Title},
+		From:      event.Contact{UserID: msg.From},
+		Timestamp: int(msg.Timestamp),
+		Text:      msg.Text,
+		Format:    msg.Format,
+	}
+}
+
+// SendUserMessage simulates message of user in chat: it is added to history
+// and delivered to the bot as newMessage event
+func (s *Server) SendUserMessage(chatID string, userID string, text string) event.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := s.addMessage(&Message{ChatID: chatID, From: userID, Text: text})
+	return s.pushEvent(event.EventNewMessage, event.Payload{BasePayload: s.basePayload(msg)})
+}
+
+// JoinChat adds users to chat members and delivers newChatMembers event
+func (s *Server) JoinChat(chatID string, userIDs ...string) event.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.chat(chatID)
+	payload := event.Payload{}
+	payload.Chat = event.Chat{ID: c.ID, Type: event.ChatType(c.Info.Type), Title: c.Info.Title}
+	for _, userID := range userIDs {
+		if c.member(userID) < 0 {
+			c.Members = append(c.Members, chatMember(userID))
+		}
+		payload.MembersNew = append(payload.MembersNew, event.Contact{UserID: userID})
+	}
+	return s.pushEvent(event.EventNewChatMembers, payload)
+}
+
+// PressButton simulates press of callback button with given text on message of the bot.
+// It delivers callbackQuery event and returns ID of the query (see CallbackAnswer)
+func (s *Server) PressButton(chatID string, msgID string, userID string, buttonText string) (queryID string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := s.message(chatID, msgID)
+	if msg == nil || msg.Deleted {
+		return "", fmt.Errorf("message %s not found in chat %s", msgID, chatID)
+	}
+	for _, row := range msg.Keyboard {
+		for _, b := range row {
+			if b.Text != buttonText {
+				continue
+			}
+			if b.Callback == "" {
+				return "", fmt.Errorf("button %q has no callback data", buttonText)
+			}
+			query := &CallbackQuery{ID: s.nextID(), ChatID: chatID, MsgID: msgID, UserID: userID, Data: b.Callback}
+			s.queries[query.ID] = query
+			s.pushEvent(event.EventCallbackQuery, event.Payload{
+				BasePayload:     event.BasePayload{From: event.Contact{UserID: userID}},
+				QueryID:         query.ID,
+				CallbackMessage: s.basePayload(msg),
+				CallbackData:    b.Callback,
+			})
+			return query.ID, nil
+		}
+	}
+	return "", fmt.Errorf("message %s has no button %q", msgID, buttonText)
+}
+
+// CallbackAnswer returns answer of the bot to callback query, if it was given
+func (s *Server) CallbackAnswer(queryID string) (message.AnswerCallback, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query, ok := s.queries[queryID]
+	if !ok || query.Answer == nil {
+		return message.AnswerCallback{}, false
+	}
+	return *query.Answer, true
+}
+
+func (s *Server) answerCallbackQuery(_ *http.Request, params url.Values) (map[string]any, string) {
+	if missing := required(params, "queryId"); missing != "" {
+		return nil, missing
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query, ok := s.queries[params.Get("queryId")]
+	if !ok {
+		return nil, "Invalid queryId"
+	}
+	if query.Answer != nil {
+		return nil, "Query is already answered"
+	}
+	query.Answer = &message.AnswerCallback{
+		QueryID:   query.ID,
+		Text:      params.Get("text"),
+		ShowAlert: params.Get("showAlert") == "true",
+		URL:       params.Get("url"),
+	}
+	s.notify()
+	return nil, ""
+}
+
+// eventsGet returns events after lastEventId, waiting up to pollTime seconds for new ones
+func (s *Server) eventsGet(w http.ResponseWriter, r *http.Request, params url.Values) {
+	lastEventID, err := strconv.Atoi(params.Get("lastEventId"))
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": false, "description": "Invalid lastEventId"})
+		return
+	}
+	pollTime, _ := strconv.Atoi(params.Get("pollTime"))
+	var events []event.Event
+	s.waitFor(r.Context(), time.Duration(pollTime)*time.Second, func() bool {
+		if lastEventID < len(s.events) {
+			events = append([]event.Event(nil), s.events[max(lastEventID, 0):]...)
+		}
+		return len(events) > 0
+	})
+	if events == nil {
+		events = []event.Event{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "events": events})
+}
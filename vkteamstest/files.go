@@ -0,0 +1,79 @@
+package vkteamstest
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// Path of file download URLs returned by /files/getInfo
+const downloadPath = "/files/download/"
+
+// File uploaded by the bot or added with AddFile
+type File struct {
+	ID       string
+	Filename string
+	// "file" or "voice" for uploads
+	Type string
+	Data []byte
+}
+
+// AddFile adds file which can be sent by ID or downloaded; returns its ID
+func (s *Server) AddFile(filename string, fileType string, data []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.addFile(&File{Filename: filename, Type: fileType, Data: slices.Clone(data)})
+	s.notify()
+	return f.ID
+}
+
+// addFile must be called with lock held
+func (s *Server) addFile(f *File) *File {
+	f.ID = "file" + s.nextID()
+	s.files[f.ID] = f
+	return f
+}
+
+// File returns copy of file by ID
+func (s *Server) File(fileID string) (File, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[fileID]
+	if !ok {
+		return File{}, false
+	}
+	clone := *f
+	clone.Data = slices.Clone(f.Data)
+	return clone, true
+}
+
+func (s *Server) filesGetInfo(_ *http.Request, params url.Values) (map[string]any, string) {
+	if missing := required(params, "fileId"); missing != "" {
+		return nil, missing
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[params.Get("fileId")]
+	if !ok {
+		return nil, "File not found"
+	}
+	return map[string]any{
+		"type":     f.Type,
+		"size":     len(f.Data),
+		"filename": f.Filename,
+		"url":      s.URL + downloadPath + url.PathEscape(f.ID),
+	}, ""
+}
+
+// download serves file contents; like real download links it does not require token
+func (s *Server) download(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.File(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(f.Data)))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+f.Filename+`"`)
+	w.Write(f.Data)
+}
@@ -0,0 +1,224 @@
+package vkteamstest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/format"
+	"github.com/s1em0nk3y/vkteams-bot/api/message"
+)
+
+// Message kept in chat history
+type Message struct {
+	ID     string
+	ChatID string
+	// ID of author; messages of the bot have its UserID
+	From          string
+	Text          string
+	ReplyMsgID    string
+	ForwardChatID string
+	ForwardMsgID  string
+	Keyboard      message.KeyboardMarkup
+	Format        *format.Format
+	// "HTML", "MarkdownV2" or empty
+	ParseMode string
+	FileID    string
+	Voice     bool
+	Edited    bool
+	Deleted   bool
+	Timestamp int64
+}
+
+func (m *Message) clone() Message {
+	clone := *m
+	clone.Keyboard = slices.Clone(m.Keyboard)
+	return clone
+}
+
+// AddMessage adds message of user to chat history without event, e.g. to reply to it later
+func (s *Server) AddMessage(chatID string, userID string, text string) Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := s.addMessage(&Message{ChatID: chatID, From: userID, Text: text})
+	s.notify()
+	return msg.clone()
+}
+
+// addMessage assigns ID to message and stores it. Must be called with lock held
+func (s *Server) addMessage(msg *Message) *Message {
+	s.chat(msg.ChatID)
+	msg.ID = s.nextID()
+	msg.Timestamp = time.Now().Unix()
+	s.messages = append(s.messages, msg)
+	return msg
+}
+
+// message returns message of chat by ID. Must be called with lock held
+func (s *Server) message(chatID string, msgID string) *Message {
+	for _, msg := range s.messages {
+		if msg.ID == msgID && msg.ChatID == chatID {
+			return msg
+		}
+	}
+	return nil
+}
+
+// Messages returns history of chat including deleted messages
+func (s *Server) Messages(chatID string) []Message {
+	return s.filterMessages(func(msg *Message) bool { return msg.ChatID == chatID })
+}
+
+// SentMessages returns messages sent by the bot to chat, excluding deleted ones
+func (s *Server) SentMessages(chatID string) []Message {
+	return s.filterMessages(func(msg *Message) bool {
+		return msg.ChatID == chatID && msg.From == s.bot.UserID && !msg.Deleted
+	})
+}
+
+func (s *Server) filterMessages(match func(*Message) bool) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []Message
+	for _, msg := range s.messages {
+		if match(msg) {
+			result = append(result, msg.clone())
+		}
+	}
+	return result
+}
+
+// decodeMessage fills message fields common for sendText, sendFile and editText.
+// Must be called with lock held
+func (s *Server) decodeMessage(params url.Values, msg *Message) string {
+	msg.ChatID = params.Get("chatId")
+	msg.ParseMode = params.Get("parseMode")
+	if raw := params.Get("inlineKeyboardMarkup"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &msg.Keyboard); err != nil {
+			return "Invalid inlineKeyboardMarkup: " + err.Error()
+		}
+		if err := msg.Keyboard.Validate(); err != nil {
+			return err.Error()
+		}
+	}
+	if raw := params.Get("format"); raw != "" {
+		msg.Format = &format.Format{}
+		if err := json.Unmarshal([]byte(raw), msg.Format); err != nil {
+			return "Invalid format: " + err.Error()
+		}
+		if msg.ParseMode != "" {
+			return "format can not be combined with parseMode"
+		}
+	}
+	if utf8.RuneCountInString(msg.Text) > message.MaxTextLength {
+		return "Text is too long"
+	}
+	if msg.ReplyMsgID = params.Get("replyMsgId"); msg.ReplyMsgID != "" && s.message(msg.ChatID, msg.ReplyMsgID) == nil {
+		return "Invalid replyMsgId"
+	}
+	msg.ForwardChatID, msg.ForwardMsgID = params.Get("forwardChatId"), params.Get("forwardMsgId")
+	if msg.ForwardMsgID != "" && s.message(msg.ForwardChatID, msg.ForwardMsgID) == nil {
+		return "Invalid forwardMsgId"
+	}
+	return ""
+}
+
+func (s *Server) sendText(_ *http.Request, params url.Values) (map[string]any, string) {
+	if missing := required(params, "chatId", "text"); missing != "" {
+		return nil, missing
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := &Message{From: s.bot.UserID, Text: params.Get("text")}
+	if description := s.decodeMessage(params, msg); description != "" {
+		return nil, description
+	}
+	s.addMessage(msg)
+	s.notify()
+	return map[string]any{"msgId": msg.ID}, ""
+}
+
+func (s *Server) sendFile(r *http.Request, params url.Values) (map[string]any, string) {
+	if missing := required(params, "chatId"); missing != "" {
+		return nil, missing
+	}
+	var upload *File
+	if params.Get("fileId") == "" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "Unable to read file: " + err.Error()
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, "Unable to read file: " + err.Error()
+		}
+		upload = &File{Filename: header.Filename, Type: "file", Data: data}
+		if r.URL.Path == "/messages/sendVoice" {
+			upload.Type = "voice"
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := &Message{From: s.bot.UserID, Text: params.Get("caption"), Voice: r.URL.Path == "/messages/sendVoice"}
+	if description := s.decodeMessage(params, msg); description != "" {
+		return nil, description
+	}
+	if upload != nil {
+		msg.FileID = s.addFile(upload).ID
+	} else if msg.FileID = params.Get("fileId"); s.files[msg.FileID] == nil {
+		return nil, "Invalid fileId"
+	}
+	s.addMessage(msg)
+	s.notify()
+	return map[string]any{"msgId": msg.ID, "fileId": msg.FileID}, ""
+}
+
+func (s *Server) editText(_ *http.Request, params url.Values) (map[string]any, string) {
+	if missing := required(params, "chatId", "msgId", "text"); missing != "" {
+		return nil, missing
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := s.message(params.Get("chatId"), params.Get("msgId"))
+	if msg == nil || msg.Deleted {
+		return nil, "Message not found"
+	}
+	if msg.From != s.bot.UserID {
+		return nil, "Only messages of the bot can be edited"
+	}
+	edited := &Message{Text: params.Get("text")}
+	if description := s.decodeMessage(params, edited); description != "" {
+		return nil, description
+	}
+	msg.Text, msg.Keyboard, msg.Format, msg.ParseMode = edited.Text, edited.Keyboard, edited.Format, edited.ParseMode
+	msg.Edited = true
+	s.notify()
+	return nil, ""
+}
+
+func (s *Server) deleteMessages(_ *http.Request, params url.Values) (map[string]any, string) {
+	if missing := required(params, "chatId", "msgId"); missing != "" {
+		return nil, missing
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []*Message
+	for _, msgID := range params["msgId"] {
+		msg := s.message(params.Get("chatId"), msgID)
+		if msg == nil || msg.Deleted {
+			return nil, "Message " + msgID + " not found"
+		}
+		messages = append(messages, msg)
+	}
+	for _, msg := range messages {
+		msg.Deleted = true
+	}
+	s.notify()
+	return nil, ""
+}
@@ -0,0 +1,279 @@
+// Package vkteamstest provides an in-process fake of VK Teams Bot API for tests.
+// It keeps chats, messages and files in memory and serves /messages/*, /events/get,
+// /chats/*, /files/* and /self/get:
+//
+//	server := vkteamstest.NewServer()
+//	defer server.Close()
+//	bot := vkteams.New(server.Token, vkteams.WithApiURL(server.URL))
+//	server.SendUserMessage("user@example.com", "user@example.com", "/start")
+//	...
+//	server.AssertSent(t, "user@example.com", "Hello!")
+package vkteamstest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/api/self"
+)
+
+const DefaultToken = "test-token"
+
+// Default identity of the bot, returned by /self/get
+var DefaultBot = self.BotInfo{
+	UserID:    "1000000000",
+	Nick:      "testbot",
+	FirstName: "Test bot",
+}
+
+// Request is a recorded API call. Token is not kept in Params
+type Request struct {
+	Method string
+	Path   string
+	Params url.Values
+}
+
+type failure struct {
+	status      int
+	description string
+}
+
+type Server struct {
+	// Base URL of API; pass it to vkteams.WithApiURL
+	URL string
+	// Token the server accepts
+	Token string
+	// How long assertions wait for expected state; default is 2 seconds
+	AssertTimeout time.Duration
+
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	bot      self.BotInfo
+	chats    map[string]*Chat
+	messages []*Message
+	files    map[string]*File
+	queries  map[string]*CallbackQuery
+	events   []event.Event
+	requests []Request
+	failures map[string][]failure
+	lastID   int
+	// Closed and replaced on every change of state
+	changed chan struct{}
+	closed  chan struct{}
+}
+
+type Option func(*Server)
+
+// WithToken sets token accepted by the server
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.Token = token
+	}
+}
+
+// WithBot sets identity of the bot
+func WithBot(info self.BotInfo) Option {
+	return func(s *Server) {
+		s.bot = info
+	}
+}
+
+// NewServer starts fake API server. Call Close when done
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		Token:         DefaultToken,
+		AssertTimeout: 2 * time.Second,
+		bot:           DefaultBot,
+		chats:         map[string]*Chat{},
+		files:         map[string]*File{},
+		queries:       map[string]*CallbackQuery{},
+		failures:      map[string][]failure{},
+		changed:       make(chan struct{}),
+		closed:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	mux := http.NewServeMux()
+	s.routes(mux)
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close releases pending long polls and shuts the server down
+func (s *Server) Close() {
+	close(s.closed)
+	s.srv.Close()
+}
+
+// Client returns HTTP client of the server; usable with vkteams.WithHTTPClient
+func (s *Server) Client() *http.Client { return s.srv.Client() }
+
+type handlerFunc func(r *http.Request, params url.Values) (response map[string]any, description string)
+
+func (s *Server) routes(mux *http.ServeMux) {
+	handlers := map[string]handlerFunc{
+		"/self/get":                     s.selfGet,
+		"/events/get":                   nil, // long poll, see eventsGet
+		"/messages/sendText":            s.sendText,
+		"/messages/sendFile":            s.sendFile,
+		"/messages/sendVoice":           s.sendFile,
+		"/messages/editText":            s.editText,
+		"/messages/deleteMessages":      s.deleteMessages,
+		"/messages/answerCallbackQuery": s.answerCallbackQuery,
+		"/files/getInfo":                s.filesGetInfo,
+		"/chats/getInfo":                s.chatsGetInfo,
+		"/chats/getAdmins":              s.chatsGetAdmins,
+		"/chats/getMembers":             s.chatsGetMembers,
+		"/chats/getBlockedUsers":        s.chatsGetBlockedUsers,
+		"/chats/getPendingUsers":        s.chatsGetPendingUsers,
+		"/chats/blockUser":              s.chatsBlockUser,
+		"/chats/unblockUser":            s.chatsUnblockUser,
+		"/chats/resolvePending":         s.chatsResolvePending,
+		"/chats/members/delete":         s.chatsDeleteMembers,
+		"/chats/setTitle":               s.chatsSetTitle,
+		"/chats/setAbout":               s.chatsSetAbout,
+		"/chats/setRules":               s.chatsSetRules,
+		"/chats/pinMessage":             s.chatsPinMessage,
+		"/chats/unpinMessage":           s.chatsUnpinMessage,
+	}
+	for path, h := range handlers {
+		mux.HandleFunc(path, s.api(path, h))
+	}
+	mux.HandleFunc("GET "+downloadPath+"{id}", s.download)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]any{"ok": false, "description": "Not found"})
+	})
+}
+
+// api records request, checks token and injected failures and runs handler
+func (s *Server) api(path string, h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		token := params.Get("token")
+		params.Del("token")
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: path, Params: params})
+		var fail *failure
+		if queue := s.failures[path]; len(queue) > 0 {
+			fail, s.failures[path] = &queue[0], queue[1:]
+		}
+		s.notify()
+		s.mu.Unlock()
+
+		switch {
+		case fail != nil:
+			response := map[string]any{"ok": false}
+			if fail.description != "" {
+				response["description"] = fail.description
+			}
+			writeJSON(w, fail.status, response)
+		case token != s.Token:
+			writeJSON(w, http.StatusOK, map[string]any{"ok": false, "description": "Invalid token"})
+		case h == nil:
+			s.eventsGet(w, r, params)
+		default:
+			response, description := h(r, params)
+			if description != "" {
+				writeJSON(w, http.StatusOK, map[string]any{"ok": false, "description": description})
+				return
+			}
+			if response == nil {
+				response = map[string]any{}
+			}
+			response["ok"] = true
+			writeJSON(w, http.StatusOK, response)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// FailNext makes the next request to path (e.g. "/messages/sendText") fail
+// with given HTTP status and description. Calls are queued
+func (s *Server) FailNext(path string, status int, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], failure{status: status, description: description})
+}
+
+// Requests returns all recorded API calls in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) selfGet(*http.Request, url.Values) (map[string]any, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]any{
+		"userId":    s.bot.UserID,
+		"nick":      s.bot.Nick,
+		"firstName": s.bot.FirstName,
+		"about":     s.bot.About,
+		"photo":     s.bot.Photo,
+	}, ""
+}
+
+// nextID returns unique ID for messages, files and queries. Must be called with lock held
+func (s *Server) nextID() string {
+	s.lastID++
+	return strconv.Itoa(s.lastID)
+}
+
+// notify wakes up waiting long polls and assertions. Must be called with lock held
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// waitFor waits until cond (called with lock held) is true, timeout expires or context is done
+func (s *Server) waitFor(ctx context.Context, timeout time.Duration, cond func() bool) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		s.mu.Lock()
+		ok, changed := cond(), s.changed
+		s.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return false
+		case <-ctx.Done():
+			return false
+		case <-s.closed:
+			return false
+		}
+	}
+}
+
+func required(params url.Values, names ...string) string {
+	var missing []string
+	for _, name := range names {
+		if params.Get(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "Missing required parameter " + strings.Join(missing, ", ")
+	}
+	return ""
+}
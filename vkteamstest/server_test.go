@@ -0,0 +1,202 @@
+package vkteamstest_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/s1em0nk3y/vkteams-bot"
+	"github.com/s1em0nk3y/vkteams-bot/api/chat"
+	"github.com/s1em0nk3y/vkteams-bot/api/event"
+	"github.com/s1em0nk3y/vkteams-bot/api/message"
+	"github.com/s1em0nk3y/vkteams-bot/retry"
+	"github.com/s1em0nk3y/vkteams-bot/router"
+	"github.com/s1em0nk3y/vkteams-bot/vkteamstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBot(server *vkteamstest.Server, opts ...vkteams.Option) *vkteams.Bot {
+	return vkteams.New(server.Token, append([]vkteams.Option{
+		vkteams.WithApiURL(server.URL),
+		vkteams.WithPollSeconds(1),
+		vkteams.WithStartMode(event.StartResume),
+	}, opts...)...)
+}
+
+func TestServer_Conversation(t *testing.T) {
+	server := vkteamstest.NewServer()
+	defer server.Close()
+	bot := newBot(server)
+
+	r := router.New()
+	r.OnNewMessage(func(ctx context.Context, ev event.Event) error {
+		_, err := bot.SendText(ctx, &message.Message{
+			ChatID:         ev.Chat.ID,
+			Text:           "You said: " + ev.Text,
+			ReplyMsgID:     ev.MessageID,
+			KeyboardMarkup: message.NewKeyboard().Row(message.CallbackButton("Like", "like")).Markup(),
+		})
+		return err
+	})
+	r.OnCallbackQuery(func(ctx context.Context, ev event.Event) error {
+		return bot.AnswerCallback(ctx, &message.AnswerCallback{QueryID: ev.QueryID, Text: "Liked " + ev.CallbackData})
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx, bot)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	sent := server.SendUserMessage("user@example.com", "user@example.com", "hello")
+	reply := server.AssertSent(t, "user@example.com", "You said: hello")
+	assert.Equal(t, sent.MessageID, reply.ReplyMsgID)
+	server.AssertNotSent(t, "user@example.com", "hello")
+
+	queryID, err := server.PressButton("user@example.com", reply.ID, "user@example.com", "Like")
+	require.NoError(t, err)
+	assert.Equal(t, "Liked like", server.AssertAnswered(t, queryID).Text)
+	_, err = server.PressButton("user@example.com", reply.ID, "user@example.com", "Dislike")
+	assert.Error(t, err)
+}
+
+func TestServer_Messages(t *testing.T) {
+	server := vkteamstest.NewServer()
+	defer server.Close()
+	bot := newBot(server)
+	ctx := context.Background()
+
+	msgID, err := bot.SendText(ctx, &message.Message{ChatID: "chat", Text: "first"})
+	require.NoError(t, err)
+	_, err = bot.SendText(ctx, &message.Message{ChatID: "chat", Text: "reply", ReplyMsgID: "unknown"})
+	assert.ErrorIs(t, err, message.ErrNotOk)
+
+	require.NoError(t, bot.EditMessage(ctx, &message.EditMessage{
+		Message:         message.Message{ChatID: "chat", Text: "edited"},
+		MessageToEditID: msgID,
+	}))
+	userMsg := server.AddMessage("chat", "user", "from user")
+	err = bot.EditMessage(ctx, &message.EditMessage{
+		Message:         message.Message{ChatID: "chat", Text: "edited"},
+		MessageToEditID: userMsg.ID,
+	})
+	assert.ErrorContains(t, err, "Only messages of the bot can be edited")
+
+	msg := server.AssertSent(t, "chat", "edited")
+	assert.True(t, msg.Edited)
+	require.NoError(t, bot.DeleteMessages(ctx, &message.DeleteMessage{ChatID: "chat", MessageIDs: []string{msgID}}))
+	assert.Empty(t, server.SentMessages("chat"))
+	assert.Len(t, server.Messages("chat"), 2)
+
+	_, fileID, err := bot.SendFile(ctx, &message.FileMessage{
+		Message:  message.Message{ChatID: "chat", Text: "report"},
+		Filename: "report.txt",
+		Contents: bytes.NewBufferString("contents"),
+	})
+	require.NoError(t, err)
+	body, info, err := bot.Download(ctx, fileID)
+	require.NoError(t, err)
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	assert.Equal(t, "contents", string(data))
+	assert.Equal(t, "report.txt", info.Filename)
+	assert.Equal(t, int64(8), info.Size)
+
+	_, _, err = bot.SendVoice(ctx, &message.FileMessage{Message: message.Message{ChatID: "chat"}, FileID: fileID})
+	assert.NoError(t, err)
+	_, _, err = bot.SendFile(ctx, &message.FileMessage{Message: message.Message{ChatID: "chat"}, FileID: "unknown"})
+	assert.ErrorIs(t, err, message.ErrNotOk)
+
+	assert.Equal(t, map[string][]string{"chatId": {"chat"}, "text": {"first"}}, map[string][]string(server.AssertCalled(t, "/messages/sendText").Params))
+}
+
+func TestServer_Chats(t *testing.T) {
+	server := vkteamstest.NewServer()
+	defer server.Close()
+	bot := newBot(server)
+	ctx := context.Background()
+	const chatID = "123@chat.agent"
+	server.AddChat(vkteamstest.Chat{
+		ID:      chatID,
+		Info:    chat.ChatInfo{Title: "Team"},
+		Members: []chat.Member{{UserID: "owner", Creator: true}, {UserID: "spammer"}},
+		Pending: []string{"newbie"},
+	})
+
+	info, err := bot.GetChatInfo(ctx, chatID)
+	require.NoError(t, err)
+	assert.Equal(t, chat.ChatInfo{Type: chat.ChatTypeGroup, Title: "Team"}, *info)
+	admins, err := bot.GetAdmins(ctx, chatID)
+	require.NoError(t, err)
+	assert.Equal(t, []chat.Admin{{UserID: "owner", Creator: true}}, admins)
+
+	require.NoError(t, bot.BlockUser(ctx, &chat.BlockUser{ChatID: chatID, UserID: "spammer"}))
+	require.NoError(t, bot.ResolvePending(ctx, &chat.ResolvePending{ChatID: chatID, Approve: true, Everyone: true}))
+	require.NoError(t, bot.SetTitle(ctx, chatID, "Dev team"))
+	page, err := bot.GetMembers(ctx, chatID, "")
+	require.NoError(t, err)
+	assert.Equal(t, []chat.Member{{UserID: "owner", Creator: true}, {UserID: "newbie"}}, page.Members)
+	require.NoError(t, bot.DeleteMembers(ctx, &chat.DeleteMembers{ChatID: chatID, UserIDs: []string{"newbie"}}))
+
+	state, ok := server.Chat(chatID)
+	require.True(t, ok)
+	assert.Equal(t, "Dev team", state.Info.Title)
+	assert.Equal(t, []string{"spammer"}, state.Blocked)
+	assert.Empty(t, state.Pending)
+	assert.Len(t, state.Members, 1)
+
+	assert.ErrorIs(t, bot.PinMessage(ctx, chatID, "unknown"), chat.ErrNotOk)
+	joined := server.JoinChat(chatID, "alice")
+	assert.Equal(t, event.EventNewChatMembers, joined.Type)
+}
+
+func TestServer_Self(t *testing.T) {
+	server := vkteamstest.NewServer()
+	defer server.Close()
+	info, err := newBot(server).Self(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, vkteamstest.DefaultBot.Nick, info.Nick)
+
+	_, err = vkteams.New("wrong", vkteams.WithApiURL(server.URL)).Self(context.Background())
+	assert.ErrorContains(t, err, "Invalid token")
+}
+
+func TestServer_FailNext(t *testing.T) {
+	server := vkteamstest.NewServer()
+	defer server.Close()
+	server.FailNext("/messages/sendText", http.StatusTooManyRequests, "")
+	bot := newBot(server, vkteams.WithRetryPolicy(retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+	_, err := bot.SendText(context.Background(), &message.Message{ChatID: "chat", Text: "retried"})
+	assert.NoError(t, err)
+	assert.Len(t, server.Requests(), 2)
+}
+
+func TestServer_LongPoll(t *testing.T) {
+	server := vkteamstest.NewServer()
+	defer server.Close()
+	bot := newBot(server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := bot.UpdatesChannel(ctx)
+
+	server.AssertCalled(t, "/events/get")
+	server.SendUserMessage("chat", "user", "one")
+	server.SendUserMessage("chat", "user", "two")
+	for _, text := range []string{"one", "two"} {
+		select {
+		case ev := <-events:
+			assert.Equal(t, text, ev.Text)
+			assert.Equal(t, "user", ev.From.UserID)
+		case <-time.After(time.Second):
+			t.Fatalf("event %q was not delivered", text)
+		}
+	}
+}